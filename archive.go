@@ -0,0 +1,205 @@
+package fcw
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ArchiveFormat is the format of a profile archive.
+type ArchiveFormat int
+
+const (
+	// ArchiveTarGz is a gzip-compressed tar archive.
+	ArchiveTarGz ArchiveFormat = iota
+	// ArchiveZip is a zip archive.
+	ArchiveZip
+)
+
+// archiveFormat returns the format of the archive at name from its extension.
+func archiveFormat(name string) (ArchiveFormat, bool) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return ArchiveTarGz, true
+	case strings.HasSuffix(lower, ".zip"):
+		return ArchiveZip, true
+	}
+	return 0, false
+}
+
+// transientNames are files and directories which only matter to the Firefox
+// instance that wrote them: locks, caches, crash reports and the session. The
+// manifest is left out too, since it records changes to the profile it is in.
+// They are matched against every path component.
+var transientNames = map[string]bool{
+	"lock":                    true,
+	".parentlock":             true,
+	"parent.lock":             true,
+	"cache2":                  true,
+	"startupCache":            true,
+	"thumbnails":              true,
+	"shader-cache":            true,
+	"safebrowsing":            true,
+	"crashes":                 true,
+	"minidumps":               true,
+	"datareporting":           true,
+	"saved-telemetry-pings":   true,
+	"sessionstore.jsonlz4":    true,
+	"sessionstore-backups":    true,
+	"sessionCheckpoints.json": true,
+	manifestName:              true,
+}
+
+// transient reports whether the slash-separated path rel inside a profile is
+// transient, see transientNames.
+func transient(rel string) bool {
+	for _, name := range strings.Split(rel, "/") {
+		if transientNames[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// archivePath checks that the archive entry name stays inside the profile and
+// returns it as a clean slash-separated path, or "" for the root.
+func archivePath(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("archive entry %q is outside the profile", name)
+	}
+	if clean == "." {
+		return "", nil
+	}
+	return clean, nil
+}
+
+// extractFile writes r to the slash-separated path rel inside dir.
+func extractFile(dir, rel string, r io.Reader, perm os.FileMode) error {
+	dest := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm.Perm()|0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// extractTarGz extracts the regular files and directories of a tar.gz archive
+// into dir, leaving out the entries skip returns true for. Links and other
+// special files are left out too.
+func extractTarGz(r io.Reader, dir string, skip func(rel string) bool) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		rel, err := archivePath(hdr.Name)
+		if err != nil {
+			return err
+		}
+		if rel == "" || skip(rel) {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(filepath.Join(dir, filepath.FromSlash(rel)), 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := extractFile(dir, rel, tr, os.FileMode(hdr.Mode)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// extractZip is extractTarGz for zip archives.
+func extractZip(zr *zip.Reader, dir string, skip func(rel string) bool) error {
+	for _, f := range zr.File {
+		rel, err := archivePath(f.Name)
+		if err != nil {
+			return err
+		}
+		if rel == "" || skip(rel) {
+			continue
+		}
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			if err := os.MkdirAll(filepath.Join(dir, filepath.FromSlash(rel)), 0o755); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			err = extractFile(dir, rel, rc, mode)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// copyTree copies the regular files and directories under src into dir,
+// leaving out the paths skip returns true for.
+func copyTree(src, dir string, skip func(rel string) bool) error {
+	return filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+		if skip(rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(filepath.Join(dir, filepath.FromSlash(rel)), 0o755)
+		case info.Mode().IsRegular():
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return extractFile(dir, rel, f, info.Mode())
+		}
+		return nil
+	})
+}
@@ -0,0 +1,115 @@
+package fcw
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// autoconfigJS points Firefox at mozilla.cfg. It goes in defaults/pref in the
+// installation directory.
+const autoconfigJS = `// Written by go-fpw. Loads mozilla.cfg from the installation directory.
+pref("general.config.filename", "mozilla.cfg");
+pref("general.config.obscure_value", 0);
+`
+
+// Autoconfig is a mozilla.cfg for a Firefox installation. Unlike user.js,
+// which only sets a preference at startup, it can lock preferences so they
+// cannot be changed in about:config or the settings. It applies to every
+// profile used with the installation, so it is meant for bundled browsers
+// dedicated to one app.
+type Autoconfig struct {
+	// Locked preferences are set and cannot be changed.
+	Locked map[string]interface{}
+	// Defaults change default values, which the user can still override.
+	Defaults map[string]interface{}
+}
+
+// Script returns the content of mozilla.cfg.
+func (a *Autoconfig) Script() (string, error) {
+	// Firefox skips the first line of mozilla.cfg.
+	lines := []string{"// Written by go-fpw."}
+	for _, set := range []struct {
+		fn    string
+		prefs map[string]interface{}
+	}{{"defaultPref", a.Defaults}, {"lockPref", a.Locked}} {
+		for _, name := range sortedPrefNames(set.prefs) {
+			line, err := formatPref(set.fn, name, set.prefs[name])
+			if err != nil {
+				return "", err
+			}
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// Write installs the autoconfig into installDir, see InstallDir, replacing
+// any mozilla.cfg which is already there.
+func (a *Autoconfig) Write(installDir string) error {
+	script, err := a.Script()
+	if err != nil {
+		return err
+	}
+	prefDir := filepath.Join(installDir, "defaults", "pref")
+	if err := os.MkdirAll(prefDir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(prefDir, "autoconfig.js"), []byte(autoconfigJS), 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(installDir, "mozilla.cfg"), []byte(script), 0o644)
+}
+
+// writeUntilClosed installs the autoconfig like Write, and returns a function
+// which puts back the mozilla.cfg and autoconfig.js which were there before,
+// or removes them if there were none. It is for locks which only make sense
+// while one browser runs, like a proxy served for that launch.
+func (a *Autoconfig) writeUntilClosed(installDir string) (func() error, error) {
+	type saved struct {
+		path    string
+		content []byte
+	}
+	var files []saved
+	for _, path := range []string{
+		filepath.Join(installDir, "defaults", "pref", "autoconfig.js"),
+		filepath.Join(installDir, "mozilla.cfg"),
+	} {
+		content, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		files = append(files, saved{path, content})
+	}
+	restore := func() error {
+		for _, f := range files {
+			if f.content == nil {
+				if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			} else if err := os.WriteFile(f.path, f.content, 0o644); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := a.Write(installDir); err != nil {
+		restore()
+		return nil, err
+	}
+	return restore, nil
+}
+
+// RemoveAutoconfig removes the files Autoconfig.Write installed into
+// installDir.
+func RemoveAutoconfig(installDir string) error {
+	for _, path := range []string{
+		filepath.Join(installDir, "defaults", "pref", "autoconfig.js"),
+		filepath.Join(installDir, "mozilla.cfg"),
+	} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package fcw
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLockedPrefsUntilClosed(t *testing.T) {
+	fakeFirefox(t)
+	installDir, err := InstallDir(FirefoxExecutable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := filepath.Join(installDir, "mozilla.cfg")
+	original := "// bundled\nlockPref(\"browser.startup.page\", 3);\n"
+	if err := os.WriteFile(cfg, []byte(original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	locked := map[string]interface{}{"media.peerconnection.enabled": false}
+	for _, c := range []struct {
+		name  string
+		opts  Options
+		locks []string
+	}{
+		{"LockedPrefs", Options{LockedPrefs: locked}, []string{"media.peerconnection.enabled"}},
+		{"LockProxy", Options{Airgap: &OfflineConfig{}, LockProxy: true, LockedPrefs: locked},
+			[]string{"media.peerconnection.enabled", "network.proxy.autoconfig_url"}},
+	} {
+		ui, err := LaunchFirefox(t.TempDir(), c.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := os.ReadFile(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range c.locks {
+			if !strings.Contains(string(content), `lockPref("`+name+`"`) {
+				t.Errorf("%s: mozilla.cfg does not lock %s:\n%s", c.name, name, content)
+			}
+		}
+		if err := ui.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if content, _ := os.ReadFile(cfg); string(content) != original {
+			t.Errorf("%s: mozilla.cfg after Close =\n%s\nwant\n%s", c.name, content, original)
+		}
+		if _, err := os.Stat(filepath.Join(installDir, "defaults", "pref", "autoconfig.js")); !os.IsNotExist(err) {
+			t.Errorf("%s: autoconfig.js left behind: %v", c.name, err)
+		}
+	}
+}
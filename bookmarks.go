@@ -0,0 +1,618 @@
+package fcw
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The GUIDs of the bookmark root folders.
+const (
+	BookmarksMenu    = "menu________"
+	BookmarksToolbar = "toolbar_____"
+	BookmarksOther   = "unfiled_____"
+)
+
+// bookmarksRoot is the GUID of the folder holding the root folders, which
+// cannot be changed.
+const bookmarksRoot = "root________"
+
+// ErrNoBookmark is returned when no bookmark or folder has the given GUID.
+var ErrNoBookmark = errors.New("bookmark or folder not found")
+
+// Bookmark is a bookmark, or a folder of them.
+type Bookmark struct {
+	// GUID identifies the bookmark in places.sqlite. It is set when
+	// bookmarks are read, and generated when they are added.
+	GUID  string
+	Title string
+	// URL is empty for folders.
+	URL      string
+	Children []Bookmark
+}
+
+// Folder reports whether b is a folder.
+func (b Bookmark) Folder() bool {
+	return b.URL == ""
+}
+
+// moz_bookmarks types.
+const (
+	bookmarkTypeBookmark = 1
+	bookmarkTypeFolder   = 2
+)
+
+// syncStatusNormal marks bookmarks Firefox Sync knows about, which need a
+// tombstone when they are removed.
+const syncStatusNormal = 2
+
+// Bookmarks returns the bookmarks and folders in the folder with the GUID
+// folder, like BookmarksToolbar, with their contents. Without places.sqlite,
+// which Firefox creates the first time it runs, it returns the bookmarks
+// seeded by AddBookmarks for Firefox to import.
+func (p *Profile) Bookmarks(folder string) ([]Bookmark, error) {
+	db, err := p.openPlaces()
+	if os.IsNotExist(err) {
+		roots, err := p.readBookmarksHTML()
+		if err != nil {
+			return nil, err
+		}
+		return roots[folder], nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	var id int64
+	if err := db.QueryRow("SELECT id FROM moz_bookmarks WHERE guid = ? AND type = ?", folder, bookmarkTypeFolder).Scan(&id); err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrNoBookmark, folder)
+	} else if err != nil {
+		return nil, err
+	}
+	return listBookmarks(db, id)
+}
+
+func listBookmarks(db *sql.DB, parent int64) ([]Bookmark, error) {
+	rows, err := db.Query(`SELECT b.id, b.type, b.title, b.guid, p.url
+		FROM moz_bookmarks b LEFT JOIN moz_places p ON b.fk = p.id
+		WHERE b.parent = ? AND b.type IN (?, ?) ORDER BY b.position`, parent, bookmarkTypeBookmark, bookmarkTypeFolder)
+	if err != nil {
+		return nil, err
+	}
+	var list []Bookmark
+	var folders []int64
+	for rows.Next() {
+		var id int64
+		var kind int
+		var title, rawURL sql.NullString
+		var b Bookmark
+		if err := rows.Scan(&id, &kind, &title, &b.GUID, &rawURL); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		b.Title, b.URL = title.String, rawURL.String
+		if kind == bookmarkTypeFolder {
+			b.URL = ""
+			folders = append(folders, id)
+		} else {
+			folders = append(folders, 0)
+		}
+		list = append(list, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, id := range folders {
+		if id == 0 {
+			continue
+		}
+		children, err := listBookmarks(db, id)
+		if err != nil {
+			return nil, err
+		}
+		list[i].Children = children
+	}
+	return list, nil
+}
+
+// AddBookmarks adds bookmarks, and folders with their contents, to the end of
+// the folder with the GUID folder. It writes places.sqlite, so Firefox must
+// not be running. Without places.sqlite, the bookmarks are seeded in
+// bookmarks.html instead, which Firefox imports the first time it runs; only
+// the root folders can be added to then.
+func (p *Profile) AddBookmarks(folder string, bookmarks ...Bookmark) error {
+	db, err := p.openPlaces()
+	if os.IsNotExist(err) {
+		return p.seedBookmarks(folder, bookmarks)
+	} else if err != nil {
+		return err
+	}
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	var parent int64
+	if err := tx.QueryRow("SELECT id FROM moz_bookmarks WHERE guid = ? AND type = ?", folder, bookmarkTypeFolder).Scan(&parent); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: %s", ErrNoBookmark, folder)
+		}
+		return err
+	}
+	w, err := newPlacesWriter(tx)
+	if err == nil {
+		err = w.insert(parent, bookmarks)
+	}
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("places.sqlite: %w", err)
+	}
+	return tx.Commit()
+}
+
+// RemoveBookmark removes the bookmark or folder with the GUID guid, and
+// everything in it. It needs places.sqlite, and Firefox must not be running.
+func (p *Profile) RemoveBookmark(guid string) error {
+	switch guid {
+	case bookmarksRoot, BookmarksMenu, BookmarksToolbar, BookmarksOther, "mobile______", "tags________":
+		return fmt.Errorf("root folder %s cannot be removed", guid)
+	}
+	db, err := p.openPlaces()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	var id, parent, position int64
+	if err := tx.QueryRow("SELECT id, parent, position FROM moz_bookmarks WHERE guid = ?", guid).Scan(&id, &parent, &position); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: %s", ErrNoBookmark, guid)
+		}
+		return err
+	}
+	w, err := newPlacesWriter(tx)
+	if err == nil {
+		err = w.remove(id)
+	}
+	if err == nil {
+		_, err = tx.Exec("UPDATE moz_bookmarks SET position = position - 1 WHERE parent = ? AND position > ?", parent, position)
+	}
+	if err == nil {
+		err = w.touch(parent)
+	}
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("places.sqlite: %w", err)
+	}
+	return tx.Commit()
+}
+
+// openPlaces opens places.sqlite, refusing while Firefox is using the profile.
+// The error satisfies os.IsNotExist if Firefox has not created it yet.
+func (p *Profile) openPlaces() (*sql.DB, error) {
+	if err := p.checkUnlocked(); err != nil {
+		return nil, err
+	}
+	return p.openSQLite("places.sqlite")
+}
+
+// placesWriter changes bookmarks in places.sqlite. Firefox keeps the
+// bookmark counts and origins of places up to date with temporary triggers
+// while it runs, so they are maintained by hand here.
+type placesWriter struct {
+	tx *sql.Tx
+	// columns holds the columns of each table, which differ between
+	// Firefox versions.
+	columns map[string]map[string]bool
+	now     int64
+}
+
+func newPlacesWriter(tx *sql.Tx) (*placesWriter, error) {
+	w := &placesWriter{
+		tx:      tx,
+		columns: map[string]map[string]bool{},
+		// PRTime, in microseconds.
+		now: time.Now().UnixNano() / 1000,
+	}
+	for _, table := range []string{"moz_places", "moz_bookmarks", "moz_bookmarks_deleted"} {
+		rows, err := tx.Query("SELECT name FROM pragma_table_info(?)", table)
+		if err != nil {
+			return nil, err
+		}
+		columns := map[string]bool{}
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			columns[name] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		w.columns[table] = columns
+	}
+	return w, nil
+}
+
+// insertRow inserts values into table, leaving out the columns this version
+// of places.sqlite does not have, and returns the new row's ID.
+func (w *placesWriter) insertRow(table string, values map[string]interface{}) (int64, error) {
+	var names, marks []string
+	for name := range values {
+		if w.columns[table][name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	args := make([]interface{}, len(names))
+	for i, name := range names {
+		marks = append(marks, "?")
+		args[i] = values[name]
+	}
+	result, err := w.tx.Exec("INSERT INTO "+table+" ("+strings.Join(names, ", ")+") VALUES ("+strings.Join(marks, ", ")+")", args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// insert adds bookmarks to the end of the folder with the ID parent.
+func (w *placesWriter) insert(parent int64, bookmarks []Bookmark) error {
+	var position int64
+	if err := w.tx.QueryRow("SELECT COALESCE(MAX(position) + 1, 0) FROM moz_bookmarks WHERE parent = ?", parent).Scan(&position); err != nil {
+		return err
+	}
+	for _, b := range bookmarks {
+		guid := b.GUID
+		if guid == "" {
+			var err error
+			if guid, err = newPlacesGUID(); err != nil {
+				return err
+			}
+		}
+		values := map[string]interface{}{
+			"type":              bookmarkTypeFolder,
+			"parent":            parent,
+			"position":          position,
+			"title":             b.Title,
+			"dateAdded":         w.now,
+			"lastModified":      w.now,
+			"guid":              guid,
+			"syncStatus":        1,
+			"syncChangeCounter": 1,
+		}
+		if !b.Folder() {
+			place, err := w.place(b.URL, b.Title)
+			if err != nil {
+				return err
+			}
+			values["type"] = bookmarkTypeBookmark
+			values["fk"] = place
+		}
+		id, err := w.insertRow("moz_bookmarks", values)
+		if err != nil {
+			return err
+		}
+		if b.Folder() {
+			if err := w.insert(id, b.Children); err != nil {
+				return err
+			}
+		}
+		position++
+	}
+	return w.touch(parent)
+}
+
+// touch marks the folder with the ID id as changed.
+func (w *placesWriter) touch(id int64) error {
+	stmt := "UPDATE moz_bookmarks SET lastModified = ?"
+	if w.columns["moz_bookmarks"]["syncChangeCounter"] {
+		stmt += ", syncChangeCounter = syncChangeCounter + 1"
+	}
+	_, err := w.tx.Exec(stmt+" WHERE id = ?", w.now, id)
+	return err
+}
+
+// place returns the ID of the place for rawURL, adding it if there is none,
+// and counts one more bookmark for it.
+func (w *placesWriter) place(rawURL, title string) (int64, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" {
+		return 0, fmt.Errorf("invalid bookmark URL %q", rawURL)
+	}
+	hash := placesURLHash(rawURL)
+	var id int64
+	err = w.tx.QueryRow("SELECT id FROM moz_places WHERE url_hash = ? AND url = ?", hash, rawURL).Scan(&id)
+	if err == nil {
+		_, err = w.tx.Exec("UPDATE moz_places SET foreign_count = foreign_count + 1 WHERE id = ?", id)
+		return id, err
+	} else if err != sql.ErrNoRows {
+		return 0, err
+	}
+	prefix, host := placesOrigin(u)
+	if _, err := w.tx.Exec("INSERT OR IGNORE INTO moz_origins (prefix, host, frecency) VALUES (?, ?, 0)", prefix, host); err != nil {
+		return 0, err
+	}
+	var origin int64
+	if err := w.tx.QueryRow("SELECT id FROM moz_origins WHERE prefix = ? AND host = ?", prefix, host).Scan(&origin); err != nil {
+		return 0, err
+	}
+	guid, err := newPlacesGUID()
+	if err != nil {
+		return 0, err
+	}
+	return w.insertRow("moz_places", map[string]interface{}{
+		"url":             rawURL,
+		"title":           title,
+		"rev_host":        placesRevHost(u),
+		"hidden":          0,
+		"frecency":        -1,
+		"recalc_frecency": 1,
+		"guid":            guid,
+		"foreign_count":   1,
+		"url_hash":        hash,
+		"origin_id":       origin,
+	})
+}
+
+// remove deletes the bookmark or folder with the ID id and everything in it.
+func (w *placesWriter) remove(id int64) error {
+	var kind, syncStatus int
+	var fk sql.NullInt64
+	var guid string
+	status := "0"
+	if w.columns["moz_bookmarks"]["syncStatus"] {
+		status = "syncStatus"
+	}
+	if err := w.tx.QueryRow("SELECT type, fk, guid, "+status+" FROM moz_bookmarks WHERE id = ?", id).Scan(&kind, &fk, &guid, &syncStatus); err != nil {
+		return err
+	}
+	if kind == bookmarkTypeFolder {
+		rows, err := w.tx.Query("SELECT id FROM moz_bookmarks WHERE parent = ?", id)
+		if err != nil {
+			return err
+		}
+		var children []int64
+		for rows.Next() {
+			var child int64
+			if err := rows.Scan(&child); err != nil {
+				rows.Close()
+				return err
+			}
+			children = append(children, child)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, child := range children {
+			if err := w.remove(child); err != nil {
+				return err
+			}
+		}
+	}
+	if fk.Valid {
+		if _, err := w.tx.Exec("UPDATE moz_places SET foreign_count = foreign_count - 1 WHERE id = ?", fk.Int64); err != nil {
+			return err
+		}
+	}
+	if syncStatus == syncStatusNormal && len(w.columns["moz_bookmarks_deleted"]) > 0 {
+		if _, err := w.tx.Exec("INSERT OR REPLACE INTO moz_bookmarks_deleted (guid, dateRemoved) VALUES (?, ?)", guid, w.now); err != nil {
+			return err
+		}
+	}
+	_, err := w.tx.Exec("DELETE FROM moz_bookmarks WHERE id = ?", id)
+	return err
+}
+
+// newPlacesGUID returns a random GUID in the format places.sqlite uses: 12
+// characters of URL-safe base64.
+func newPlacesGUID() (string, error) {
+	b := make([]byte, 9)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// placesRevHost returns the host of u reversed, with a trailing dot, as it is
+// stored in moz_places.rev_host.
+func placesRevHost(u *url.URL) string {
+	host := strings.ToLower(u.Hostname())
+	b := []byte(host)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b) + "."
+}
+
+// placesOrigin returns the prefix and host of u as they are stored in
+// moz_origins.
+func placesOrigin(u *url.URL) (prefix, host string) {
+	switch {
+	case u.Scheme == "file":
+		return "file:///", ""
+	case u.Host == "":
+		return u.Scheme + ":", ""
+	}
+	return u.Scheme + "://", strings.ToLower(u.Host)
+}
+
+// hashString is mozilla::HashString, over bytes.
+func hashString(s string) uint32 {
+	const goldenRatio = 0x9E3779B9
+	var hash uint32
+	for i := 0; i < len(s); i++ {
+		hash = goldenRatio * ((hash<<5 | hash>>27) ^ uint32(s[i]))
+	}
+	return hash
+}
+
+// placesURLHash is the hash_url() SQL function of places.sqlite, which
+// moz_places.url_hash holds. The scheme's hash goes in the 16 bits above the
+// hash of the URL, so URLs of one scheme can be found by a range.
+func placesURLHash(rawURL string) int64 {
+	const maxCharsToHash = 1500
+	s := rawURL
+	if len(s) > maxCharsToHash {
+		s = s[:maxCharsToHash]
+	}
+	hash := uint64(hashString(s))
+	head := rawURL
+	if len(head) > 50 {
+		head = head[:50]
+	}
+	if i := strings.IndexByte(head, ':'); i >= 0 {
+		hash += uint64(hashString(rawURL[:i])&0xFFFF) << 32
+	}
+	return int64(hash)
+}
+
+// seedBookmarks adds bookmarks to the bookmarks.html Firefox imports when it
+// creates places.sqlite.
+func (p *Profile) seedBookmarks(folder string, bookmarks []Bookmark) error {
+	switch folder {
+	case BookmarksMenu, BookmarksToolbar, BookmarksOther:
+	default:
+		return fmt.Errorf("until Firefox has created places.sqlite, bookmarks can only be added to the root folders, not %s", folder)
+	}
+	roots, err := p.readBookmarksHTML()
+	if err != nil {
+		return err
+	}
+	roots[folder] = append(roots[folder], bookmarks...)
+	if err := p.writeFile("bookmarks.html", bookmarksHTML(roots), 0o644); err != nil {
+		return err
+	}
+	// Firefox turns the preference off again after the import, which it
+	// could not do if it were set in user.js.
+	return p.setPrefsIn("prefs.js", map[string]interface{}{"browser.places.importBookmarksHTML": true})
+}
+
+// bookmarksHTML writes the root folders in roots in the Netscape bookmark file
+// format Firefox imports.
+func bookmarksHTML(roots map[string][]Bookmark) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("<!DOCTYPE NETSCAPE-Bookmark-file-1>\n")
+	buf.WriteString("<META HTTP-EQUIV=\"Content-Type\" CONTENT=\"text/html; charset=UTF-8\">\n")
+	buf.WriteString("<TITLE>Bookmarks</TITLE>\n<H1>Bookmarks Menu</H1>\n\n<DL><p>\n")
+	writeBookmarksHTML(&buf, roots[BookmarksMenu], 1)
+	for _, root := range []struct{ guid, attr, title string }{
+		{BookmarksToolbar, "PERSONAL_TOOLBAR_FOLDER", "Bookmarks Toolbar"},
+		{BookmarksOther, "UNFILED_BOOKMARKS_FOLDER", "Other Bookmarks"},
+	} {
+		if len(roots[root.guid]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "    <DT><H3 %s=\"true\">%s</H3>\n    <DL><p>\n", root.attr, root.title)
+		writeBookmarksHTML(&buf, roots[root.guid], 2)
+		buf.WriteString("    </DL><p>\n")
+	}
+	buf.WriteString("</DL>\n")
+	return buf.Bytes()
+}
+
+func writeBookmarksHTML(buf *bytes.Buffer, bookmarks []Bookmark, depth int) {
+	indent := strings.Repeat("    ", depth)
+	for _, b := range bookmarks {
+		if b.Folder() {
+			fmt.Fprintf(buf, "%s<DT><H3>%s</H3>\n%s<DL><p>\n", indent, html.EscapeString(b.Title), indent)
+			writeBookmarksHTML(buf, b.Children, depth+1)
+			fmt.Fprintf(buf, "%s</DL><p>\n", indent)
+		} else {
+			fmt.Fprintf(buf, "%s<DT><A HREF=\"%s\">%s</A>\n", indent, html.EscapeString(b.URL), html.EscapeString(b.Title))
+		}
+	}
+}
+
+var (
+	bookmarkHref  = regexp.MustCompile(`(?i)HREF="([^"]*)"`)
+	bookmarkTitle = regexp.MustCompile(`(?i)>([^<]*)</(A|H3)>`)
+)
+
+// readBookmarksHTML reads the root folders from the profile's bookmarks.html,
+// which has one element per line like the files Firefox and bookmarksHTML
+// write.
+func (p *Profile) readBookmarksHTML() (map[string][]Bookmark, error) {
+	roots := map[string][]Bookmark{}
+	data, err := os.ReadFile(filepath.Join(p.dir, "bookmarks.html"))
+	if os.IsNotExist(err) {
+		return roots, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSpace(scanner.Text()))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for i, line := range lines {
+		if strings.HasPrefix(strings.ToUpper(line), "<DL>") {
+			roots[BookmarksMenu], _ = parseBookmarksHTML(lines, i+1, roots)
+			break
+		}
+	}
+	return roots, nil
+}
+
+// parseBookmarksHTML reads the list of bookmarks starting at lines[i], up to
+// its closing </DL>, and returns it with the index of the line after that.
+// The toolbar and other bookmarks folders are added to roots instead.
+func parseBookmarksHTML(lines []string, i int, roots map[string][]Bookmark) ([]Bookmark, int) {
+	var list []Bookmark
+	for i < len(lines) {
+		line := lines[i]
+		upper := strings.ToUpper(line)
+		i++
+		title := ""
+		if m := bookmarkTitle.FindStringSubmatch(line); m != nil {
+			title = html.UnescapeString(m[1])
+		}
+		switch {
+		case strings.HasPrefix(upper, "</DL>"):
+			return list, i
+		case strings.HasPrefix(upper, "<DT><H3"):
+			var children []Bookmark
+			if i < len(lines) && strings.HasPrefix(strings.ToUpper(lines[i]), "<DL>") {
+				children, i = parseBookmarksHTML(lines, i+1, roots)
+			}
+			switch {
+			case strings.Contains(upper, "PERSONAL_TOOLBAR_FOLDER"):
+				roots[BookmarksToolbar] = append(roots[BookmarksToolbar], children...)
+			case strings.Contains(upper, "UNFILED_BOOKMARKS_FOLDER"):
+				roots[BookmarksOther] = append(roots[BookmarksOther], children...)
+			default:
+				list = append(list, Bookmark{Title: title, Children: children})
+			}
+		case strings.HasPrefix(upper, "<DT><A "):
+			if m := bookmarkHref.FindStringSubmatch(line); m != nil {
+				list = append(list, Bookmark{Title: title, URL: html.UnescapeString(m[1])})
+			}
+		}
+	}
+	return list, i
+}
@@ -0,0 +1,136 @@
+package fcw
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var placesSchema = []string{
+	"CREATE TABLE moz_origins (id INTEGER PRIMARY KEY, prefix TEXT NOT NULL, host TEXT NOT NULL, frecency INTEGER NOT NULL, UNIQUE (prefix, host))",
+	"CREATE TABLE moz_places (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR, rev_host LONGVARCHAR, visit_count INTEGER DEFAULT 0, hidden INTEGER DEFAULT 0 NOT NULL, typed INTEGER DEFAULT 0 NOT NULL, frecency INTEGER DEFAULT -1 NOT NULL, last_visit_date INTEGER, guid TEXT, foreign_count INTEGER DEFAULT 0 NOT NULL, url_hash INTEGER DEFAULT 0 NOT NULL, origin_id INTEGER)",
+	"CREATE TABLE moz_bookmarks (id INTEGER PRIMARY KEY, type INTEGER, fk INTEGER DEFAULT NULL, parent INTEGER, position INTEGER, title LONGVARCHAR, keyword_id INTEGER, folder_type TEXT, dateAdded INTEGER, lastModified INTEGER, guid TEXT, syncStatus INTEGER NOT NULL DEFAULT 0, syncChangeCounter INTEGER NOT NULL DEFAULT 1)",
+	"CREATE TABLE moz_bookmarks_deleted (guid TEXT PRIMARY KEY, dateRemoved INTEGER NOT NULL DEFAULT 0)",
+	"INSERT INTO moz_bookmarks (id, type, parent, position, guid) VALUES (1, 2, 0, 0, 'root________'), (2, 2, 1, 0, 'menu________'), (3, 2, 1, 1, 'toolbar_____'), (5, 2, 1, 3, 'unfiled_____')",
+}
+
+func TestPlacesURLHash(t *testing.T) {
+	for _, rawURL := range []string{"https://example.com/", "about:blank", "no scheme"} {
+		hash := placesURLHash(rawURL)
+		if low := uint32(hash); low != hashString(rawURL) {
+			t.Errorf("%s: low bits %x, want %x", rawURL, low, hashString(rawURL))
+		}
+	}
+	if high := placesURLHash("https://example.com/") >> 32; high != int64(hashString("https")&0xFFFF) {
+		t.Errorf("high bits %x are not the scheme's hash", high)
+	}
+	if high := placesURLHash("no scheme") >> 32; high != 0 {
+		t.Errorf("high bits %x for a URL without a scheme", high)
+	}
+}
+
+func TestBookmarks(t *testing.T) {
+	dir := t.TempDir()
+	p := NewProfile(dir)
+	db, err := sql.Open("sqlite", filepath.Join(dir, "places.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range placesSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tools := []Bookmark{
+		{Title: "Wiki", URL: "https://wiki.example.com/"},
+		{Title: "Tools", Children: []Bookmark{
+			{Title: "Tracker", URL: "https://tracker.example.com/"},
+			{Title: "Wiki again", URL: "https://wiki.example.com/"},
+		}},
+	}
+	if err := p.AddBookmarks(BookmarksToolbar, tools...); err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.Bookmarks(BookmarksToolbar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].GUID == "" || len(got[1].Children) != 2 {
+		t.Fatalf("Bookmarks = %+v", got)
+	}
+	clearGUIDs(got)
+	if !reflect.DeepEqual(got, tools) {
+		t.Errorf("Bookmarks = %+v, want %+v", got, tools)
+	}
+
+	var places, foreign int
+	var revHost string
+	db.QueryRow("SELECT count(*) FROM moz_places").Scan(&places)
+	db.QueryRow("SELECT foreign_count, rev_host FROM moz_places WHERE url = 'https://wiki.example.com/'").Scan(&foreign, &revHost)
+	if places != 2 || foreign != 2 || revHost != "moc.elpmaxe.ikiw." {
+		t.Errorf("%d places, wiki foreign_count %d, rev_host %q; want 2, 2, moc.elpmaxe.ikiw.", places, foreign, revHost)
+	}
+	var origins int
+	db.QueryRow("SELECT count(*) FROM moz_origins WHERE prefix = 'https://'").Scan(&origins)
+	if origins != 2 {
+		t.Errorf("%d origins, want 2", origins)
+	}
+
+	all, _ := p.Bookmarks(BookmarksToolbar)
+	if err := p.RemoveBookmark(all[1].GUID); err != nil {
+		t.Fatal(err)
+	}
+	db.QueryRow("SELECT foreign_count FROM moz_places WHERE url = 'https://wiki.example.com/'").Scan(&foreign)
+	if foreign != 1 {
+		t.Errorf("wiki foreign_count %d after removing the folder, want 1", foreign)
+	}
+	if got, _ := p.Bookmarks(BookmarksToolbar); len(got) != 1 {
+		t.Errorf("%d bookmarks left on the toolbar, want 1", len(got))
+	}
+	if err := p.RemoveBookmark(all[1].GUID); !errors.Is(err, ErrNoBookmark) {
+		t.Errorf("removing a removed bookmark = %v, want ErrNoBookmark", err)
+	}
+}
+
+func TestSeedBookmarks(t *testing.T) {
+	p := NewProfile(t.TempDir())
+	toolbar := []Bookmark{
+		{Title: "Tools & more", Children: []Bookmark{{Title: "Tracker", URL: "https://tracker.example.com/?a=1&b=2"}}},
+	}
+	menu := []Bookmark{{Title: "Wiki", URL: "https://wiki.example.com/"}}
+	if err := p.AddBookmarks(BookmarksToolbar, toolbar...); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddBookmarks(BookmarksMenu, menu...); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddBookmarks("abcdefghijkl", menu...); err == nil {
+		t.Error("seeding a folder other than a root succeeded")
+	}
+	for folder, want := range map[string][]Bookmark{BookmarksToolbar: toolbar, BookmarksMenu: menu} {
+		got, err := p.Bookmarks(folder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("seeded %s = %+v, want %+v", folder, got, want)
+		}
+	}
+	pf, err := loadPrefFile(filepath.Join(p.Path(), "prefs.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := pf.get("browser.places.importBookmarksHTML"); v != true {
+		t.Error("bookmarks.html import is not turned on in prefs.js")
+	}
+}
+
+func clearGUIDs(bookmarks []Bookmark) {
+	for i := range bookmarks {
+		bookmarks[i].GUID = ""
+		clearGUIDs(bookmarks[i].Children)
+	}
+}
@@ -0,0 +1,157 @@
+package fcw
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Problem is something wrong with a profile, found by Profile.Check.
+type Problem struct {
+	// File is the file the problem is in, relative to the profile.
+	File string
+	// Problem describes what is wrong.
+	Problem string
+	// Repair suggests how to fix it.
+	Repair string
+}
+
+func (p Problem) String() string {
+	return p.File + ": " + p.Problem + ". " + p.Repair
+}
+
+// sqliteStores are the databases Check runs an integrity check on, with the
+// repair to suggest when one fails.
+var sqliteStores = []struct {
+	name, repair string
+}{
+	{"places.sqlite", "Close Firefox and use Verify Integrity under Places Database in about:support, or move places.sqlite aside; Firefox starts with empty history and restores bookmarks from bookmarkbackups"},
+	{"cookies.sqlite", "Close Firefox and remove cookies.sqlite; Firefox creates a new one, signing you out of sites"},
+	{"permissions.sqlite", "Close Firefox and remove permissions.sqlite; site permissions are reset"},
+	{"cert9.db", "Close Firefox and remove cert9.db; imported certificates and trust settings have to be set up again"},
+	{"key4.db", "Close Firefox and restore key4.db from a backup; without it, saved logins cannot be decrypted"},
+}
+
+// Check looks for damage in the profile: SQLite stores which fail their
+// integrity check, preference files which do not parse, an invalid
+// extensions.json and, for App mode profiles, a missing userChrome.css. It
+// returns the problems found, each with a suggested repair, and an error only
+// if the profile could not be checked at all. Results are not reliable while
+// Firefox is using the profile.
+func (p *Profile) Check() ([]Problem, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	var problems []Problem
+	for _, store := range sqliteStores {
+		if problem := p.checkSQLite(store.name); problem != "" {
+			problems = append(problems, Problem{File: store.name, Problem: problem, Repair: store.repair})
+		}
+	}
+	for _, file := range []struct {
+		name, repair string
+	}{
+		{"prefs.js", "Close Firefox and remove the line; Firefox rewrites prefs.js when it exits"},
+		{"user.js", "Fix or remove the line; Firefox ignores the rest of user.js after it"},
+	} {
+		if problem := p.checkPrefFile(file.name); problem != "" {
+			problems = append(problems, Problem{File: file.name, Problem: problem, Repair: file.repair})
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(p.dir, "extensions.json")); err == nil && !json.Valid(data) {
+		problems = append(problems, Problem{
+			File:    "extensions.json",
+			Problem: "not valid JSON",
+			Repair:  "Close Firefox and remove extensions.json; Firefox rebuilds it from the installed extensions",
+		})
+	}
+	css := filepath.Join("chrome", "userChrome.css")
+	if p.appMode() {
+		if _, err := os.Stat(filepath.Join(p.dir, css)); os.IsNotExist(err) {
+			problems = append(problems, Problem{
+				File:    filepath.ToSlash(css),
+				Problem: "missing, but the profile is set up for App mode",
+				Repair:  "Run UnpackApp on the profile again, or launch it with Options.App",
+			})
+		}
+	}
+	return problems, nil
+}
+
+// checkSQLite runs an integrity check on the SQLite database name, and
+// describes what is wrong with it, or returns "" if it is fine or missing.
+func (p *Profile) checkSQLite(name string) string {
+	path := filepath.Join(p.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	db, err := sql.Open("sqlite", "file:"+filepath.ToSlash(path)+"?mode=ro&_pragma=busy_timeout(1000)")
+	if err != nil {
+		return "cannot be opened: " + err.Error()
+	}
+	defer db.Close()
+	rows, err := db.Query("PRAGMA integrity_check")
+	if err != nil {
+		return "cannot be read: " + err.Error()
+	}
+	defer rows.Close()
+	var messages []string
+	for rows.Next() {
+		var message string
+		if err := rows.Scan(&message); err != nil {
+			return "cannot be read: " + err.Error()
+		}
+		if message != "ok" {
+			messages = append(messages, message)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "cannot be read: " + err.Error()
+	}
+	if len(messages) > 0 {
+		return "failed the integrity check: " + strings.Join(messages, "; ")
+	}
+	return ""
+}
+
+// checkPrefFile describes the first statement in the preference file name
+// which does not parse, or returns "" if they all do or it is missing.
+func (p *Profile) checkPrefFile(name string) string {
+	pf, err := loadPrefFile(filepath.Join(p.dir, name))
+	if err != nil {
+		return "cannot be read: " + err.Error()
+	}
+	comment := false
+	for i, line := range pf.lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case comment:
+			comment = !strings.Contains(trimmed, "*/")
+			continue
+		case strings.HasPrefix(trimmed, "/*"):
+			comment = !strings.Contains(trimmed, "*/")
+			continue
+		case trimmed == "", strings.HasPrefix(trimmed, "//"), strings.HasPrefix(trimmed, "#"):
+			continue
+		}
+		_, raw, ok := parsePrefLine(line)
+		if !ok {
+			return fmt.Sprintf("line %d is not a preference statement", i+1)
+		}
+		if _, err := parsePrefValue(raw); err != nil {
+			return fmt.Sprintf("line %d: %v", i+1, err)
+		}
+	}
+	return ""
+}
+
+// appMode reports whether the profile is expected to be in App mode, because
+// UnpackApp recorded userChrome.css in the manifest. The stylesheets pref on
+// its own is not enough, since users turn it on for their own userChrome.css.
+func (p *Profile) appMode() bool {
+	m, err := p.Manifest()
+	return err == nil && m.hasFile(filepath.Join("chrome", "userChrome.css"))
+}
@@ -0,0 +1,77 @@
+package fcw
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	p := NewProfile(dir)
+	db, err := sql.Open("sqlite", filepath.Join(dir, "places.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("CREATE TABLE moz_places (id INTEGER PRIMARY KEY, url TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	for name, content := range map[string]string{
+		"prefs.js":        "// Mozilla User Preferences\n\n/* Do not edit this file.\n */\nuser_pref(\"toolkit.legacyUserProfileCustomizations.stylesheets\", true);\n",
+		"user.js":         "user_pref(\"browser.startup.page\", 3);\nuser_pref(\"browser.startup.homepage\", https://example.com);\n",
+		"extensions.json": "{\"addons\": [",
+		"cookies.sqlite":  "this is not a database, it is far too short to be one and has no header",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	problems, err := p.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]Problem{}
+	for _, problem := range problems {
+		found[problem.File] = problem
+	}
+	for _, name := range []string{"cookies.sqlite", "user.js", "extensions.json"} {
+		problem, ok := found[name]
+		if !ok {
+			t.Errorf("no problem reported for %s", name)
+		} else if problem.Repair == "" {
+			t.Errorf("no repair suggested for %s", name)
+		}
+	}
+	if problem := found["user.js"]; problem.Problem != "line 2: invalid preference value https://example.com" {
+		t.Errorf("user.js problem = %q", problem.Problem)
+	}
+	// Custom stylesheets are turned on, but not by UnpackApp.
+	for _, name := range []string{"places.sqlite", "prefs.js", "chrome/userChrome.css"} {
+		if problem, ok := found[name]; ok {
+			t.Errorf("healthy %s reported: %s", name, problem)
+		}
+	}
+}
+
+func TestCheckAppMode(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := UnpackApp(dir, true); err != nil {
+		t.Fatal(err)
+	}
+	p := NewProfile(dir)
+	if problems, err := p.Check(); err != nil || len(problems) != 0 {
+		t.Errorf("Check of an App mode profile = %v, %v", problems, err)
+	}
+	if err := os.Remove(filepath.Join(dir, "chrome", "userChrome.css")); err != nil {
+		t.Fatal(err)
+	}
+	problems, err := p.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || problems[0].File != "chrome/userChrome.css" {
+		t.Errorf("Check without userChrome.css = %v, want it reported", problems)
+	}
+}
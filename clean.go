@@ -0,0 +1,179 @@
+package fcw
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	// Registers the "sqlite" database/sql driver.
+	_ "modernc.org/sqlite"
+)
+
+// cleanNames are the transient files and directories Profile.Clean always
+// removes: caches, crash reports, telemetry and session backups.
+var cleanNames = []string{
+	"cache2",
+	"startupCache",
+	"thumbnails",
+	"shader-cache",
+	"OfflineCache",
+	"jumpListCache",
+	"crashes",
+	"minidumps",
+	"saved-telemetry-pings",
+	"sessionstore-backups",
+}
+
+// CleanOptions selects what Profile.Clean removes besides caches and other
+// transient files.
+type CleanOptions struct {
+	// History clears the browsing, download and form history and the saved
+	// session. Bookmarks are kept.
+	History bool
+	// Cookies removes every cookie.
+	Cookies bool
+	// SiteStorage removes the local storage, IndexedDB and cache storage
+	// sites keep. Storage sites were granted as persistent is kept.
+	SiteStorage bool
+}
+
+// historySQL clears the history from places.sqlite, keyed by the table each
+// statement needs. Places with a foreign_count are bookmarked or have a
+// keyword, and are kept.
+var historySQL = []struct {
+	table, stmt string
+}{
+	{"moz_historyvisits", "DELETE FROM moz_historyvisits"},
+	{"moz_inputhistory", "DELETE FROM moz_inputhistory"},
+	{"moz_places_metadata", "DELETE FROM moz_places_metadata"},
+	{"moz_places_metadata_search_queries", "DELETE FROM moz_places_metadata_search_queries"},
+	{"moz_places", "DELETE FROM moz_places WHERE foreign_count = 0"},
+	{"moz_places", "UPDATE moz_places SET visit_count = 0, last_visit_date = NULL"},
+	{"moz_annos", "DELETE FROM moz_annos WHERE place_id NOT IN (SELECT id FROM moz_places)"},
+	{"moz_origins", "DELETE FROM moz_origins WHERE id NOT IN (SELECT origin_id FROM moz_places)"},
+}
+
+// Clean removes caches, crash reports, session backups and similar transient
+// data from the profile, and whatever else opts selects. It returns the number
+// of bytes freed. It refuses to run while Firefox is using the profile.
+func (p *Profile) Clean(opts CleanOptions) (int64, error) {
+	if err := p.Validate(); err != nil {
+		return 0, err
+	}
+	if err := p.checkUnlocked(); err != nil {
+		return 0, err
+	}
+	before, err := dirSize(p.dir)
+	if err != nil {
+		return 0, err
+	}
+	remove := append([]string{}, cleanNames...)
+	if opts.History {
+		remove = append(remove, "sessionstore.jsonlz4", "formhistory.sqlite")
+	}
+	if opts.Cookies {
+		remove = append(remove, "cookies.sqlite")
+	}
+	if opts.SiteStorage {
+		remove = append(remove, "webappsstore.sqlite", filepath.Join("storage", "default"), filepath.Join("storage", "temporary"))
+	}
+	for _, name := range remove {
+		for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+			if err := os.RemoveAll(filepath.Join(p.dir, name+suffix)); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if opts.History {
+		if err := p.clearHistory(); err != nil {
+			return 0, err
+		}
+	}
+	after, err := dirSize(p.dir)
+	if err != nil {
+		return 0, err
+	}
+	return before - after, nil
+}
+
+// openSQLite opens the SQLite database name in the profile, which must exist.
+func (p *Profile) openSQLite(name string) (*sql.DB, error) {
+	path := filepath.Join(p.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return openSQLiteFile(path)
+}
+
+// openSQLiteFile opens the SQLite database at path, creating it if it does
+// not exist.
+func openSQLiteFile(path string) (*sql.DB, error) {
+	return sql.Open("sqlite", "file:"+filepath.ToSlash(path)+"?_pragma=busy_timeout(1000)")
+}
+
+// sqliteTables returns the names of the tables in db.
+func sqliteTables(db *sql.DB) (map[string]bool, error) {
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tables := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables[name] = true
+	}
+	return tables, rows.Err()
+}
+
+// clearHistory removes everything but bookmarks from places.sqlite.
+func (p *Profile) clearHistory() error {
+	db, err := p.openSQLite("places.sqlite")
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer db.Close()
+	tables, err := sqliteTables(db)
+	if err != nil {
+		return fmt.Errorf("places.sqlite: %w", err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, s := range historySQL {
+		if !tables[s.table] {
+			continue
+		}
+		if _, err := tx.Exec(s.stmt); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("places.sqlite: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	_, err = db.Exec("VACUUM")
+	return err
+}
+
+// dirSize returns the total size of the files under dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,92 @@
+package fcw
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestClean(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"prefs.js":                                           "user_pref(\"browser.startup.page\", 3);\n",
+		"cache2/entries/ABCD":                                "cached",
+		"startupCache/startupCache.8.little":                 "cached",
+		"sessionstore-backups/recovery.jsonlz4":              "session",
+		"cookies.sqlite":                                     "cookies",
+		"storage/default/https+++example.com/ls/data.sqlite": "storage",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := NewProfile(dir)
+	db, err := sql.Open("sqlite", filepath.Join(dir, "places.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE moz_origins (id INTEGER PRIMARY KEY, host TEXT)",
+		"CREATE TABLE moz_places (id INTEGER PRIMARY KEY, url TEXT, visit_count INTEGER, last_visit_date INTEGER, foreign_count INTEGER, origin_id INTEGER)",
+		"CREATE TABLE moz_historyvisits (id INTEGER PRIMARY KEY, place_id INTEGER)",
+		"CREATE TABLE moz_bookmarks (id INTEGER PRIMARY KEY, fk INTEGER)",
+		"INSERT INTO moz_origins VALUES (1, 'example.com'), (2, 'example.org')",
+		"INSERT INTO moz_places VALUES (1, 'https://example.com/', 3, 1, 1, 1), (2, 'https://example.org/', 5, 1, 0, 2)",
+		"INSERT INTO moz_historyvisits VALUES (1, 1), (2, 2)",
+		"INSERT INTO moz_bookmarks VALUES (1, 1)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	freed, err := p.Clean(CleanOptions{History: true, Cookies: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if freed <= 0 {
+		t.Errorf("Clean freed %d bytes", freed)
+	}
+	for name, want := range map[string]bool{
+		"prefs.js":             true,
+		"cache2":               false,
+		"startupCache":         false,
+		"sessionstore-backups": false,
+		"cookies.sqlite":       false,
+		"storage/default":      true,
+	} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); (err == nil) != want {
+			t.Errorf("%s exists = %v after Clean, want %v", name, err == nil, want)
+		}
+	}
+	db, err = p.openSQLite("places.sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var places, visits, origins int
+	db.QueryRow("SELECT count(*) FROM moz_places").Scan(&places)
+	db.QueryRow("SELECT count(*) FROM moz_historyvisits").Scan(&visits)
+	db.QueryRow("SELECT count(*) FROM moz_origins").Scan(&origins)
+	if places != 1 || visits != 0 || origins != 1 {
+		t.Errorf("after clearing history: %d places, %d visits, %d origins; want 1, 0, 1", places, visits, origins)
+	}
+
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink("127.0.0.1:+"+strconv.Itoa(os.Getpid()), filepath.Join(dir, "lock")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.Clean(CleanOptions{}); !errors.Is(err, ErrProfileLocked) {
+			t.Errorf("Clean of a locked profile = %v, want ErrProfileLocked", err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package fcw
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// TRRMode selects when Firefox resolves names over DNS-over-HTTPS, which it
+// calls the Trusted Recursive Resolver.
+type TRRMode int
+
+const (
+	// TRROff uses the operating system's resolver only.
+	TRROff TRRMode = iota
+	// TRRFirst uses DNS-over-HTTPS, falling back to the system resolver.
+	TRRFirst
+	// TRROnly uses DNS-over-HTTPS and never falls back.
+	TRROnly
+)
+
+// network.trr.mode values for each TRRMode.
+var trrModes = map[TRRMode]int{
+	TRROff:   5,
+	TRRFirst: 2,
+	TRROnly:  3,
+}
+
+// DefaultTRRExclusions are domains which are never resolved over
+// DNS-over-HTTPS: overlay network names and local names.
+var DefaultTRRExclusions = []string{"i2p", "onion", "local", "localhost", "lan", "home.arpa"}
+
+// DoHConfig describes the DNS-over-HTTPS resolver a profile uses.
+type DoHConfig struct {
+	Mode TRRMode
+	// URL is the resolver's DNS-over-HTTPS endpoint. It is required unless
+	// Mode is TRROff.
+	URL string
+	// Bootstrap is the IP address of the resolver, used instead of looking
+	// its host name up with the system resolver.
+	Bootstrap string
+	// Exclude lists domains, with their subdomains, which are always resolved
+	// by the system resolver, in addition to DefaultTRRExclusions.
+	Exclude []string
+}
+
+// Validate reports whether the configuration is complete and consistent.
+func (c *DoHConfig) Validate() error {
+	if _, ok := trrModes[c.Mode]; !ok {
+		return fmt.Errorf("unknown TRR mode %d", c.Mode)
+	}
+	if c.Mode == TRROff {
+		return nil
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid DNS-over-HTTPS URL: %w", err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("DNS-over-HTTPS URL %q must be an https URL", c.URL)
+	}
+	if c.Bootstrap != "" && net.ParseIP(c.Bootstrap) == nil {
+		return fmt.Errorf("DNS-over-HTTPS bootstrap address %q is not an IP address", c.Bootstrap)
+	}
+	for _, domain := range c.Exclude {
+		if domain == "" || strings.ContainsAny(domain, ", ") {
+			return fmt.Errorf("invalid excluded domain %q", domain)
+		}
+	}
+	return nil
+}
+
+// Prefs returns the Firefox preferences which apply the configuration.
+func (c *DoHConfig) Prefs() (map[string]interface{}, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	excluded := append(append([]string{}, DefaultTRRExclusions...), c.Exclude...)
+	return map[string]interface{}{
+		"network.trr.mode":             trrModes[c.Mode],
+		"network.trr.uri":              c.URL,
+		"network.trr.custom_uri":       c.URL,
+		"network.trr.bootstrapAddr":    c.Bootstrap,
+		"network.trr.bootstrapAddress": c.Bootstrap,
+		"network.trr.excluded-domains": strings.Join(excluded, ","),
+	}, nil
+}
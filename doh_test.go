@@ -0,0 +1,68 @@
+package fcw
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDoHConfigValidate(t *testing.T) {
+	valid := []DoHConfig{
+		{},
+		{Mode: TRROff, URL: "not checked"},
+		{Mode: TRRFirst, URL: "https://dns.example.com/dns-query"},
+		{Mode: TRROnly, URL: "https://dns.example.com/dns-query", Bootstrap: "2001:db8::53", Exclude: []string{"intranet.example.com"}},
+	}
+	for _, c := range valid {
+		if err := c.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v", c, err)
+		}
+	}
+	invalid := []DoHConfig{
+		{Mode: TRRMode(-1)},
+		{Mode: TRROnly + 1, URL: "https://dns.example.com/dns-query"},
+		{Mode: TRRFirst},
+		{Mode: TRRFirst, URL: "http://dns.example.com/dns-query"},
+		{Mode: TRROnly, URL: "https:///dns-query"},
+		{Mode: TRROnly, URL: "https://dns.example.com/dns-query", Bootstrap: "dns.example.com"},
+		{Mode: TRROnly, URL: "https://dns.example.com/dns-query", Exclude: []string{""}},
+		{Mode: TRROnly, URL: "https://dns.example.com/dns-query", Exclude: []string{"a.example.com,b.example.com"}},
+	}
+	for _, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", c)
+		}
+	}
+}
+
+func TestDoHConfigPrefs(t *testing.T) {
+	for mode, want := range map[TRRMode]int{TRROff: 5, TRRFirst: 2, TRROnly: 3} {
+		c := DoHConfig{Mode: mode, URL: "https://dns.example.com/dns-query", Bootstrap: "192.0.2.53", Exclude: []string{"corp"}}
+		prefs, err := c.Prefs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prefs["network.trr.mode"] != want {
+			t.Errorf("mode %d: network.trr.mode = %v, want %d", mode, prefs["network.trr.mode"], want)
+		}
+		for _, name := range []string{"network.trr.uri", "network.trr.custom_uri"} {
+			if prefs[name] != c.URL {
+				t.Errorf("mode %d: %s = %v", mode, name, prefs[name])
+			}
+		}
+		if prefs["network.trr.bootstrapAddr"] != "192.0.2.53" {
+			t.Errorf("mode %d: network.trr.bootstrapAddr = %v", mode, prefs["network.trr.bootstrapAddr"])
+		}
+		excluded := prefs["network.trr.excluded-domains"]
+		if want := strings.Join(append(append([]string{}, DefaultTRRExclusions...), "corp"), ","); excluded != want {
+			t.Errorf("mode %d: excluded domains %v, want %s", mode, excluded, want)
+		}
+		for _, name := range []string{"i2p", "onion", "localhost"} {
+			if !strings.Contains(excluded.(string), name) {
+				t.Errorf("mode %d: %s is not excluded by default", mode, name)
+			}
+		}
+	}
+	if _, err := (&DoHConfig{Mode: TRRFirst}).Prefs(); err == nil {
+		t.Error("Prefs of an invalid configuration succeeded")
+	}
+}
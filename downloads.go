@@ -0,0 +1,239 @@
+package fcw
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DownloadAction is what Firefox does with a downloaded file of a MIME type.
+type DownloadAction int
+
+const (
+	// DownloadSave saves the file without asking.
+	DownloadSave DownloadAction = iota
+	// DownloadAsk asks what to do with the file.
+	DownloadAsk
+	// DownloadOpenInternally opens the file in Firefox, like PDFs in its
+	// PDF viewer.
+	DownloadOpenInternally
+	// DownloadOpenSystem saves the file and opens it with the system's
+	// default application.
+	DownloadOpenSystem
+)
+
+// handlers.json action values for each DownloadAction.
+var handlerActions = map[DownloadAction]int{
+	DownloadSave:           0,
+	DownloadAsk:            0,
+	DownloadOpenInternally: 3,
+	DownloadOpenSystem:     4,
+}
+
+// downloadPollInterval is how often a DownloadWatcher looks at its directory.
+var downloadPollInterval = 500 * time.Millisecond
+
+// DownloadConfig sets where downloads go and what Firefox does with them.
+type DownloadConfig struct {
+	// Dir is the directory downloads are saved to. It is created if it
+	// does not exist. Empty keeps Firefox's downloads directory.
+	Dir string
+	// AlwaysAsk asks where to save every download instead of saving it to
+	// Dir.
+	AlwaysAsk bool
+	// Actions maps MIME types, like "application/pdf", to what Firefox does
+	// with them. They are written to the profile's handlers.json.
+	Actions map[string]DownloadAction
+	// OnComplete is called with the path of each download completed in Dir
+	// while Firefox runs. It needs Dir and is called from its own
+	// goroutine.
+	OnComplete func(path string)
+}
+
+// Validate reports whether the configuration is complete and consistent.
+func (c *DownloadConfig) Validate() error {
+	for mimeType, action := range c.Actions {
+		if !strings.Contains(mimeType, "/") {
+			return fmt.Errorf("invalid MIME type %q", mimeType)
+		}
+		if _, ok := handlerActions[action]; !ok {
+			return fmt.Errorf("unknown download action %d for %s", action, mimeType)
+		}
+	}
+	if c.OnComplete != nil && c.Dir == "" {
+		return fmt.Errorf("watching for completed downloads needs a download directory")
+	}
+	return nil
+}
+
+// Prefs returns the Firefox preferences which apply the configuration.
+func (c *DownloadConfig) Prefs() (map[string]interface{}, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	prefs := map[string]interface{}{
+		"browser.download.useDownloadDir":                       !c.AlwaysAsk,
+		"browser.download.always_ask_before_handling_new_types": c.AlwaysAsk,
+	}
+	if c.Dir != "" {
+		dir, err := filepath.Abs(c.Dir)
+		if err != nil {
+			return nil, err
+		}
+		// 2 makes Firefox use browser.download.dir.
+		prefs["browser.download.folderList"] = 2
+		prefs["browser.download.dir"] = dir
+	}
+	return prefs, nil
+}
+
+// setDownloadActions merges actions into the profile's handlers.json, keeping
+// the handlers for every other MIME type and scheme.
+func (p *Profile) setDownloadActions(actions map[string]DownloadAction) error {
+	if len(actions) == 0 {
+		return nil
+	}
+	handlers := map[string]interface{}{}
+	data, err := os.ReadFile(filepath.Join(p.dir, "handlers.json"))
+	if err == nil {
+		if err := json.Unmarshal(data, &handlers); err != nil {
+			return fmt.Errorf("handlers.json: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	mimeTypes, _ := handlers["mimeTypes"].(map[string]interface{})
+	if mimeTypes == nil {
+		mimeTypes = map[string]interface{}{}
+	}
+	for mimeType, action := range actions {
+		handler, _ := mimeTypes[mimeType].(map[string]interface{})
+		if handler == nil {
+			handler = map[string]interface{}{}
+		}
+		handler["action"] = handlerActions[action]
+		handler["ask"] = action == DownloadAsk
+		mimeTypes[mimeType] = handler
+	}
+	handlers["mimeTypes"] = mimeTypes
+	if _, ok := handlers["defaultHandlersVersion"]; !ok {
+		handlers["defaultHandlersVersion"] = map[string]interface{}{}
+	}
+	if _, ok := handlers["schemes"]; !ok {
+		handlers["schemes"] = map[string]interface{}{}
+	}
+	data, err = json.Marshal(handlers)
+	if err != nil {
+		return err
+	}
+	return p.writeFile("handlers.json", data, 0o644)
+}
+
+// DownloadWatcher reports downloads as they complete in a directory.
+type DownloadWatcher struct {
+	dir        string
+	onComplete func(path string)
+	stop       chan struct{}
+	stopOnce   sync.Once
+	done       sync.WaitGroup
+}
+
+// WatchDownloads calls onComplete with the path of each file which finishes
+// downloading into dir from now on. Firefox downloads into a ".part" file
+// next to the final one, so a file is complete once its ".part" file is gone
+// and its size stops changing. Files already in dir are not reported.
+func WatchDownloads(dir string, onComplete func(path string)) (*DownloadWatcher, error) {
+	seen, err := scanDownloads(dir)
+	if err != nil {
+		return nil, err
+	}
+	w := &DownloadWatcher{
+		dir:        dir,
+		onComplete: onComplete,
+		stop:       make(chan struct{}),
+	}
+	w.done.Add(1)
+	go w.watch(seen)
+	return w, nil
+}
+
+// scanDownloads returns the size of each file in dir.
+func scanDownloads(dir string) (map[string]int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sizes := map[string]int64{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// Removed since ReadDir, like a finished ".part" file.
+			continue
+		}
+		sizes[entry.Name()] = info.Size()
+	}
+	return sizes, nil
+}
+
+func (w *DownloadWatcher) watch(seen map[string]int64) {
+	defer w.done.Done()
+	ticker := time.NewTicker(downloadPollInterval)
+	defer ticker.Stop()
+	// pending holds the last size of files which are not known to be
+	// complete yet.
+	pending := map[string]int64{}
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+		sizes, err := scanDownloads(w.dir)
+		if err != nil {
+			log.Println("Watching downloads:", err)
+			continue
+		}
+		for name, size := range sizes {
+			if strings.HasSuffix(name, ".part") {
+				continue
+			}
+			if last, ok := seen[name]; ok && last == size {
+				continue
+			}
+			if _, downloading := sizes[name+".part"]; downloading {
+				delete(pending, name)
+				continue
+			}
+			if last, ok := pending[name]; !ok || last != size {
+				pending[name] = size
+				continue
+			}
+			delete(pending, name)
+			seen[name] = size
+			w.onComplete(filepath.Join(w.dir, name))
+		}
+		for name := range seen {
+			if _, ok := sizes[name]; !ok {
+				delete(seen, name)
+			}
+		}
+	}
+}
+
+// Close stops watching. onComplete is not called after Close returns. Close
+// can be called more than once.
+func (w *DownloadWatcher) Close() error {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	w.done.Wait()
+	return nil
+}
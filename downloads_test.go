@@ -0,0 +1,105 @@
+package fcw
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDownloadActions(t *testing.T) {
+	dir := t.TempDir()
+	original := `{"defaultHandlersVersion":{"en-US":4},"mimeTypes":{"text/csv":{"action":2,"ask":true}},"schemes":{"mailto":{"stubEntry":true}}}`
+	if err := os.WriteFile(filepath.Join(dir, "handlers.json"), []byte(original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := NewProfile(dir)
+	if err := p.record(func() error {
+		return p.setDownloadActions(map[string]DownloadAction{
+			"application/pdf": DownloadOpenInternally,
+			"text/csv":        DownloadAsk,
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "handlers.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var handlers struct {
+		MimeTypes map[string]struct {
+			Action int  `json:"action"`
+			Ask    bool `json:"ask"`
+		} `json:"mimeTypes"`
+		Schemes map[string]interface{} `json:"schemes"`
+	}
+	if err := json.Unmarshal(data, &handlers); err != nil {
+		t.Fatal(err)
+	}
+	if pdf := handlers.MimeTypes["application/pdf"]; pdf.Action != 3 || pdf.Ask {
+		t.Errorf("application/pdf handler = %+v", pdf)
+	}
+	if csv := handlers.MimeTypes["text/csv"]; csv.Action != 0 || !csv.Ask {
+		t.Errorf("text/csv handler = %+v", csv)
+	}
+	if _, ok := handlers.Schemes["mailto"]; !ok {
+		t.Error("mailto scheme handler was dropped")
+	}
+
+	if err := p.Restore(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "handlers.json")); string(data) != original {
+		t.Errorf("handlers.json after Restore = %s", data)
+	}
+}
+
+func TestWatchDownloads(t *testing.T) {
+	defer func(interval time.Duration) { downloadPollInterval = interval }(downloadPollInterval)
+	downloadPollInterval = 10 * time.Millisecond
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "old.txt"), []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	completed := make(chan string, 10)
+	w, err := WatchDownloads(dir, func(path string) { completed <- path })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// Firefox creates an empty placeholder and downloads into a ".part"
+	// file, which is renamed over the placeholder when it is done.
+	final := filepath.Join(dir, "report.pdf")
+	for name, content := range map[string]string{final: "", final + ".part": "partial"} {
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case path := <-completed:
+		t.Fatalf("%s reported while downloading", path)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := os.Rename(final+".part", final); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case path := <-completed:
+		if path != final {
+			t.Errorf("completed %s, want %s", path, final)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("completed download was not reported")
+	}
+	select {
+	case path := <-completed:
+		t.Errorf("%s reported again", path)
+	case <-time.After(100 * time.Millisecond):
+	}
+	// The deferred Close closes the watcher a second time.
+	if err := w.Close(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,430 @@
+package fcw
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrChecksum is returned when a file in an imported archive does not match
+// the checksum in its manifest.
+var ErrChecksum = errors.New("checksum mismatch")
+
+// exportManifestName is the file in an exported archive which holds its
+// ExportManifest.
+const exportManifestName = "fpw-export.json"
+
+// Component is a kind of data in a profile which can be left out of an
+// export or import.
+type Component string
+
+const (
+	// ComponentCaches are the network, startup and thumbnail caches.
+	ComponentCaches Component = "caches"
+	// ComponentHistory is the browsing, download and form history and the
+	// saved session. Bookmarks are kept in the same database as history
+	// and go with it.
+	ComponentHistory Component = "history"
+	// ComponentCookies are cookies and site storage.
+	ComponentCookies Component = "cookies"
+	// ComponentCredentials are saved logins and the key database which
+	// encrypts them.
+	ComponentCredentials Component = "credentials"
+)
+
+// Components lists every Component.
+var Components = []Component{ComponentCaches, ComponentHistory, ComponentCookies, ComponentCredentials}
+
+// componentNames are the top-level files and directories of each Component.
+// SQLite databases include their -wal, -shm and -journal files.
+var componentNames = map[Component][]string{
+	ComponentCaches:      {"cache2", "startupCache", "thumbnails", "shader-cache", "safebrowsing", "OfflineCache", "jumpListCache"},
+	ComponentHistory:     {"places.sqlite", "favicons.sqlite", "formhistory.sqlite", "sessionstore.jsonlz4", "sessionstore-backups", "sessionCheckpoints.json"},
+	ComponentCookies:     {"cookies.sqlite", "webappsstore.sqlite", "storage", "storage.sqlite"},
+	ComponentCredentials: {"logins.json", "logins-backup.json", "key4.db", "key3.db", "signons.sqlite"},
+}
+
+// lockNames are the lock files of a running Firefox, which are never exported
+// or imported.
+var lockNames = map[string]bool{"lock": true, ".parentlock": true, "parent.lock": true}
+
+// componentOf returns the Component the slash-separated path rel inside a
+// profile belongs to, or "" if it is not part of one.
+func componentOf(rel string) Component {
+	top := strings.SplitN(rel, "/", 2)[0]
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		top = strings.TrimSuffix(top, suffix)
+	}
+	for component, names := range componentNames {
+		for _, name := range names {
+			if top == name {
+				return component
+			}
+		}
+	}
+	return ""
+}
+
+// excludeFunc returns the skip function for archive entries which leaves out
+// locks, the Manifest of changes made to the profile and the exclude
+// components.
+func excludeFunc(exclude []Component) func(rel string) bool {
+	excluded := map[Component]bool{}
+	for _, component := range exclude {
+		excluded[component] = true
+	}
+	return func(rel string) bool {
+		if lockNames[rel] || rel == manifestName {
+			return true
+		}
+		component := componentOf(rel)
+		return component != "" && excluded[component]
+	}
+}
+
+// ExportManifest describes an exported profile archive.
+type ExportManifest struct {
+	// FirefoxVersion is the version of Firefox which last used the profile,
+	// from its compatibility.ini.
+	FirefoxVersion string `json:"firefoxVersion,omitempty"`
+	// Created is when the archive was written.
+	Created time.Time `json:"created"`
+	// Components lists the Components in the archive.
+	Components []Component `json:"components"`
+	// Files maps the slash-separated path of every file in the archive to
+	// its hex-encoded SHA-256 checksum.
+	Files map[string]string `json:"files"`
+}
+
+// ExportOptions configures Profile.Export.
+type ExportOptions struct {
+	Format ArchiveFormat
+	// Exclude lists the Components left out of the archive.
+	Exclude []Component
+}
+
+// ImportOptions configures ImportProfile.
+type ImportOptions struct {
+	// Exclude lists the Components of the archive which are not imported.
+	Exclude []Component
+}
+
+// archiveWriter writes the files of an export in either format.
+type archiveWriter interface {
+	dir(rel string, info os.FileInfo) error
+	file(rel string, info os.FileInfo) (io.Writer, error)
+	Close() error
+}
+
+type tarGzWriter struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+func (w *tarGzWriter) dir(rel string, info os.FileInfo) error {
+	return w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     rel + "/",
+		Mode:     int64(info.Mode().Perm()),
+		ModTime:  info.ModTime(),
+	})
+}
+
+func (w *tarGzWriter) file(rel string, info os.FileInfo) (io.Writer, error) {
+	err := w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     rel,
+		Mode:     int64(info.Mode().Perm()),
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+	})
+	return w.tw, err
+}
+
+func (w *tarGzWriter) Close() error {
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	return w.gz.Close()
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (w *zipWriter) dir(rel string, info os.FileInfo) error {
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	hdr.Name = rel + "/"
+	_, err = w.zw.CreateHeader(hdr)
+	return err
+}
+
+func (w *zipWriter) file(rel string, info os.FileInfo) (io.Writer, error) {
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return nil, err
+	}
+	hdr.Name = rel
+	hdr.Method = zip.Deflate
+	return w.zw.CreateHeader(hdr)
+}
+
+func (w *zipWriter) Close() error {
+	return w.zw.Close()
+}
+
+// Export writes the profile to w as an archive, with an ExportManifest listing
+// its contents. Lock files and the profile's Manifest, which only describes
+// this copy, are always left out. Files change while Firefox runs, so Export
+// fails with ErrProfileLocked if it is using the profile.
+func (p *Profile) Export(w io.Writer, opts ExportOptions) error {
+	if err := p.checkUnlocked(); err != nil {
+		return err
+	}
+	var aw archiveWriter
+	switch opts.Format {
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(w)
+		aw = &tarGzWriter{gz: gz, tw: tar.NewWriter(gz)}
+	case ArchiveZip:
+		aw = &zipWriter{zw: zip.NewWriter(w)}
+	default:
+		return fmt.Errorf("unknown archive format %d", opts.Format)
+	}
+	manifest := &ExportManifest{
+		FirefoxVersion: p.lastVersion(),
+		Created:        time.Now().UTC(),
+		Files:          map[string]string{},
+	}
+	excluded := map[Component]bool{}
+	for _, component := range opts.Exclude {
+		excluded[component] = true
+	}
+	for _, component := range Components {
+		if !excluded[component] {
+			manifest.Components = append(manifest.Components, component)
+		}
+	}
+	skip := excludeFunc(opts.Exclude)
+	err := filepath.Walk(p.dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(p.dir, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." || rel == exportManifestName {
+			return nil
+		}
+		if skip(rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return aw.dir(rel, info)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		fw, err := aw.file(rel, info)
+		if err != nil {
+			return err
+		}
+		sum := sha256.New()
+		if _, err := io.CopyN(io.MultiWriter(fw, sum), f, info.Size()); err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		manifest.Files[rel] = hex.EncodeToString(sum.Sum(nil))
+		return nil
+	})
+	if err != nil {
+		aw.Close()
+		return err
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		aw.Close()
+		return err
+	}
+	info := manifestFileInfo{size: int64(len(data)), modTime: manifest.Created}
+	fw, err := aw.file(exportManifestName, info)
+	if err == nil {
+		_, err = fw.Write(data)
+	}
+	if cerr := aw.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// manifestFileInfo describes the ExportManifest to the archive writers.
+type manifestFileInfo struct {
+	size    int64
+	modTime time.Time
+}
+
+func (i manifestFileInfo) Name() string       { return exportManifestName }
+func (i manifestFileInfo) Size() int64        { return i.size }
+func (i manifestFileInfo) Mode() os.FileMode  { return 0o644 }
+func (i manifestFileInfo) ModTime() time.Time { return i.modTime }
+func (i manifestFileInfo) IsDir() bool        { return false }
+func (i manifestFileInfo) Sys() interface{}   { return nil }
+
+// ImportProfile extracts a profile archive written by Profile.Export into dir,
+// which is created if it does not exist and must be empty. Every extracted
+// file is checked against the archive's manifest. If anything goes wrong, dir
+// is left empty.
+func ImportProfile(r io.Reader, dir string, opts ImportOptions) (*ExportManifest, error) {
+	p := NewProfile(dir)
+	if err := p.Create(); err != nil {
+		return nil, err
+	}
+	empty, err := p.empty()
+	if err != nil {
+		return nil, err
+	}
+	if !empty {
+		return nil, &ProfileError{Path: dir, Err: ErrProfileNotEmpty}
+	}
+	manifest, err := p.importArchive(r, opts)
+	if err != nil {
+		p.clear()
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func (p *Profile) importArchive(r io.Reader, opts ImportOptions) (*ExportManifest, error) {
+	// Zip archives can only be read with random access, so the archive is
+	// spooled to a temporary file first.
+	tmp, err := os.CreateTemp("", "fpw-import-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	magic, err := bufio.NewReader(tmp).Peek(2)
+	if err != nil {
+		return nil, fmt.Errorf("not a profile archive: %w", err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	skip := excludeFunc(opts.Exclude)
+	switch string(magic) {
+	case "\x1f\x8b":
+		err = extractTarGz(tmp, p.dir, skip)
+	case "PK":
+		var zr *zip.Reader
+		zr, err = zip.NewReader(tmp, size)
+		if err == nil {
+			err = extractZip(zr, p.dir, skip)
+		}
+	default:
+		return nil, fmt.Errorf("not a tar.gz or zip archive")
+	}
+	if err != nil {
+		return nil, err
+	}
+	manifestPath := filepath.Join(p.dir, exportManifestName)
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("archive has no %s: %w", exportManifestName, err)
+	}
+	manifest := &ExportManifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("%s: %w", exportManifestName, err)
+	}
+	if err := os.Remove(manifestPath); err != nil {
+		return nil, err
+	}
+	for rel, want := range manifest.Files {
+		if skip(rel) {
+			continue
+		}
+		got, err := fileChecksum(filepath.Join(p.dir, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, err
+		}
+		if got != want {
+			return nil, fmt.Errorf("%w: %s", ErrChecksum, rel)
+		}
+	}
+	err = filepath.Walk(p.dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(p.dir, file)
+		if err != nil {
+			return err
+		}
+		if _, ok := manifest.Files[filepath.ToSlash(rel)]; !ok {
+			return fmt.Errorf("%w: %s is not in the manifest", ErrChecksum, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// fileChecksum returns the hex-encoded SHA-256 checksum of the file at path.
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sum := sha256.New()
+	if _, err := io.Copy(sum, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// lastVersion returns the version of Firefox which last used the profile,
+// from its compatibility.ini, or "" if it is not known.
+func (p *Profile) lastVersion() string {
+	ini, err := readINI(filepath.Join(p.dir, "compatibility.ini"))
+	if err != nil {
+		return ""
+	}
+	for _, section := range ini {
+		if section.name == "Compatibility" {
+			version, _ := section.get("LastVersion")
+			return version
+		}
+	}
+	return ""
+}
@@ -0,0 +1,125 @@
+package fcw
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestExportImport(t *testing.T) {
+	src := t.TempDir()
+	files := map[string]string{
+		"prefs.js":              "user_pref(\"browser.startup.page\", 3);\n",
+		"compatibility.ini":     "[Compatibility]\nLastVersion=128.0_20240708000000/20240708000000\n",
+		"chrome/userChrome.css": "#nav-bar { display: none; }\n",
+		"logins.json":           "{}",
+		"key4.db":               "secret",
+		"places.sqlite":         "history",
+		".parentlock":           "",
+		manifestName:            `{"files": {"chrome/userChrome.css": null}}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, format := range []ArchiveFormat{ArchiveTarGz, ArchiveZip} {
+		var archive bytes.Buffer
+		if err := NewProfile(src).Export(&archive, ExportOptions{Format: format, Exclude: []Component{ComponentCredentials}}); err != nil {
+			t.Fatal(err)
+		}
+		dir := filepath.Join(t.TempDir(), "imported")
+		manifest, err := ImportProfile(bytes.NewReader(archive.Bytes()), dir, ImportOptions{Exclude: []Component{ComponentHistory}})
+		if err != nil {
+			t.Fatalf("format %d: %v", format, err)
+		}
+		if manifest.FirefoxVersion != "128.0_20240708000000/20240708000000" {
+			t.Errorf("format %d: FirefoxVersion = %q", format, manifest.FirefoxVersion)
+		}
+		for _, component := range manifest.Components {
+			if component == ComponentCredentials {
+				t.Errorf("format %d: manifest lists excluded credentials", format)
+			}
+		}
+		for name, content := range files {
+			data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+			switch name {
+			case "logins.json", "key4.db", "places.sqlite", ".parentlock", manifestName:
+				if err == nil {
+					t.Errorf("format %d: excluded %s was imported", format, name)
+				}
+			default:
+				if string(data) != content {
+					t.Errorf("format %d: %s = %q, %v; want %q", format, name, data, err, content)
+				}
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, exportManifestName)); !os.IsNotExist(err) {
+			t.Errorf("format %d: manifest left in the profile", format)
+		}
+	}
+
+	var tampered bytes.Buffer
+	zw := zip.NewWriter(&tampered)
+	w, _ := zw.Create("prefs.js")
+	w.Write([]byte("user_pref(\"browser.startup.page\", 1);\n"))
+	w, _ = zw.Create(exportManifestName)
+	w.Write([]byte(`{"files": {"prefs.js": "0000"}}`))
+	zw.Close()
+	dir := t.TempDir()
+	if _, err := ImportProfile(bytes.NewReader(tampered.Bytes()), dir, ImportOptions{}); !errors.Is(err, ErrChecksum) {
+		t.Errorf("importing a tampered archive = %v, want ErrChecksum", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("failed import left %d files behind", len(entries))
+	}
+}
+
+func TestImportIgnoresManifest(t *testing.T) {
+	prefs := "user_pref(\"browser.startup.page\", 3);\n"
+	sum := sha256.Sum256([]byte(prefs))
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	w, _ := zw.Create("prefs.js")
+	w.Write([]byte(prefs))
+	w, _ = zw.Create(manifestName)
+	w.Write([]byte(`{"prefs": {"user.js": {"browser.startup.page": null}}}`))
+	w, _ = zw.Create(exportManifestName)
+	w.Write([]byte(`{"files": {"prefs.js": "` + hex.EncodeToString(sum[:]) + `"}}`))
+	zw.Close()
+	dir := t.TempDir()
+	if _, err := ImportProfile(bytes.NewReader(archive.Bytes()), dir, ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, manifestName)); !os.IsNotExist(err) {
+		t.Errorf("%s was imported: %v", manifestName, err)
+	}
+}
+
+func TestExportLocked(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a lock symlink")
+	}
+	dir := t.TempDir()
+	if err := os.Symlink("127.0.0.1:+"+strconv.Itoa(os.Getpid()), filepath.Join(dir, "lock")); err != nil {
+		t.Fatal(err)
+	}
+	var archive bytes.Buffer
+	if err := NewProfile(dir).Export(&archive, ExportOptions{}); !errors.Is(err, ErrProfileLocked) {
+		t.Errorf("exporting a profile in use = %v, want ErrProfileLocked", err)
+	}
+	if archive.Len() != 0 {
+		t.Errorf("wrote %d bytes of a profile in use", archive.Len())
+	}
+}
@@ -31,7 +31,6 @@ package fcw
 
 import (
 	"embed"
-	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
@@ -53,223 +52,99 @@ var UserOverrides []byte
 var dummy embed.FS
 
 // BasicFirefox sets up a new Firefox instance, and creates the profile directory if
-// it does not already exist.
+// it does not already exist. The profile directory's parent must exist.
 func BasicFirefox(userdir string, private bool, args ...string) (UI, error) {
-	userdir = directory(userdir)
-	add := true
-	var cleanedArgs []string
-	if private {
-		cleanedArgs = append(cleanedArgs, "--private-window")
-	}
-	for _, arg := range args {
-		if arg == "--private-window" {
-			if private {
-				add = false
-			} else {
-				add = true
-			}
-		}
-		if arg != "" {
-			if add {
-				cleanedArgs = append(cleanedArgs, arg)
-			}
-		}
-	}
-	log.Println("Args", cleanedArgs)
-	userdir, err := filepath.Abs(userdir)
-	if err != nil {
-		return nil, err
-	}
-	return NewFirefox("", userdir, 800, 600, cleanedArgs...)
-}
-
-func increment(i *int) int {
-	*i++
-	return *i
+	return LaunchFirefox(userdir, Options{Private: private}, args...)
 }
 
 // WebAppFirefox sets up a new Firefox instance, and creates the profile directory if
-// it does not already exist. It turns Firefox into a WebApp-Viewer with the provided
+// it does not already exist. The profile directory's parent must exist. It turns Firefox into a WebApp-Viewer with the provided
 // profile
 func WebAppFirefox(userdir string, private, offline bool, args ...string) (UI, error) {
-	// i := 0
-	// log.Println(increment(&i))
-	userdir = directory(userdir)
-	// log.Println(increment(&i))
-	add := true
-	var cleanedArgs []string
-	if private {
-		cleanedArgs = append(cleanedArgs, "--private-window")
-	}
-	// log.Println(increment(&i))
-	for _, arg := range args {
-		if arg == "--private-window" {
-			if private {
-				add = false
-			} else {
-				add = true
-			}
-		}
-		if arg != "" {
-			if add {
-				cleanedArgs = append(cleanedArgs, arg)
-			}
-		}
-	}
-	log.Println("Args", cleanedArgs)
-	userdir, err := filepath.Abs(userdir)
-	if err != nil {
-		return nil, err
-	}
-	log.Println("Unpacking App" + userdir)
-	userdir, err = UnpackApp(userdir, offline)
-	if err != nil {
-		return nil, err
-	}
-	log.Println("Unpacked App" + userdir)
-	ui, err := NewFirefox("", userdir, 800, 600, cleanedArgs...)
-	if err != nil {
-		return nil, err
-	}
-	return ui, nil
+	return LaunchFirefox(userdir, Options{Private: private, App: true, Offline: offline}, args...)
 }
 
 // UnpackApp unpacks a "App" mode profile into the "profileDir" and returns the
 // path to the profile and possibly, an error if something goes wrong. If everything
-// works, the error will be nil
+// works, the error will be nil. Every file and preference it adds or changes is
+// recorded in the profile's manifest, so Profile.Restore can revert them.
 func UnpackApp(profileDir string, offline bool) (string, error) {
-	if err := os.MkdirAll(filepath.Join(profileDir, "chrome"), 0o755); err != nil {
-		return filepath.Join(profileDir), err
-	}
-	if err := forceUserChromeCSS(filepath.Join(profileDir, "chrome", "userChrome.css")); err != nil {
-		return filepath.Join(profileDir), err
-	}
-	if err := os.WriteFile(filepath.Join(profileDir, "user.js"), UserOverrides, 0o644); err != nil {
-		log.Println(err)
-	}
-	if err := appifyUserJS(filepath.Join(profileDir, "user-overrides.js"), offline); err != nil {
-		return filepath.Join(profileDir), err
-	}
-	if err := appifyUserJS(filepath.Join(profileDir, "user.js"), offline); err != nil {
-		return filepath.Join(profileDir), err
-	}
-	if err := appifyUserJS(filepath.Join(profileDir, "prefs.js"), offline); err != nil {
-		return filepath.Join(profileDir), err
+	p := NewProfile(profileDir)
+	if err := p.record(func() error {
+		return p.unpackApp(offline)
+	}); err != nil {
+		return profileDir, err
 	}
 	return profileDir, nil
 }
 
-/*
-
-
- */
+// appPrefs are set in every preference file of an "App" mode profile. They
+// allow the bundled extensions to load and enable userChrome.css.
+var appPrefs = map[string]interface{}{
+	"extensions.autoDisableScopes":                        0,
+	"extensions.enabledScopes":                            1,
+	"toolkit.legacyUserProfileCustomizations.stylesheets": true,
+}
 
-func appifyUserJS(profile string, offline bool) error {
-	prefset := "user_pref(\"extensions.autoDisableScopes\", 0);\n"
-	prefset += "user_pref(\"extensions.enabledScopes\", 1);\n"
-	prefset += "user_pref(\"toolkit.legacyUserProfileCustomizations.stylesheets\", true);\n"
-	extDir := filepath.Join(filepath.Dir(profile), "extensions")
-	if err := os.MkdirAll(extDir, 0o755); err != nil {
+func (p *Profile) unpackApp(offline bool) error {
+	if err := p.mkdir("chrome"); err != nil {
+		return err
+	}
+	if err := p.writeFile(filepath.Join("chrome", "userChrome.css"), UserChrome, 0o644); err != nil {
 		return err
 	}
-	if err := os.WriteFile(filepath.Join(extDir, "{786c38ae-eac8-41df-ad3b-3c737603bead}.xpi"), extraExtension, 0o644); err != nil {
+	if err := p.mkdir("extensions"); err != nil {
+		return err
+	}
+	if err := p.writeFile(filepath.Join("extensions", "{786c38ae-eac8-41df-ad3b-3c737603bead}.xpi"), extraExtension, 0o644); err != nil {
 		return err
 	}
 	if offline {
-		if err := os.WriteFile(filepath.Join(extDir, "awo@eyedeekay.github.io.xpi"), offlineExtension, 0o644); err != nil {
+		if err := p.writeFile(filepath.Join("extensions", "awo@eyedeekay.github.io.xpi"), offlineExtension, 0o644); err != nil {
 			return err
 		}
 	}
-	if _, err := os.Stat(profile); err != nil {
-		if strings.Contains(profile, "prefs.js") {
-			if err := ioutil.WriteFile(profile, []byte(prefset), 0o644); err != nil {
-				return err
-			}
-		}
-	} else {
-		content, err := ioutil.ReadFile(profile)
-		if err != nil {
+	userPrefs := userOverridePrefs()
+	for name, value := range appPrefs {
+		userPrefs[name] = value
+	}
+	if err := p.setPrefsIn("user.js", userPrefs); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(p.dir, "user-overrides.js")); err == nil {
+		if err := p.setPrefsIn("user-overrides.js", appPrefs); err != nil {
 			return err
 		}
-		finished := false
-		lines := strings.Split(string(content), "\n")
-		for i, line := range lines {
-			if strings.Contains(line, "toolkit.legacyUserProfileCustomizations.stylesheets\"") {
-				if strings.Contains(line, "true") {
-					return nil
-				} else {
-					line = strings.Replace(line, "false", "true", 1)
-					finished = true
-				}
-			}
-			lines[i] = line
-		}
-		out := strings.Join(lines, "\n")
-		if err := ioutil.WriteFile(profile, []byte(out), 0o644); err != nil {
-			return err
+	}
+	return p.setPrefsIn("prefs.js", appPrefs)
+}
+
+// userOverridePrefs returns the preferences set by UserOverrides.
+func userOverridePrefs() map[string]interface{} {
+	prefs := map[string]interface{}{}
+	for _, line := range strings.Split(string(UserOverrides), "\n") {
+		name, raw, ok := parsePrefLine(line)
+		if !ok {
+			continue
 		}
-		if !finished {
-			f, err := os.OpenFile(profile,
-				os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
-			if err != nil {
-				return err
-			}
-			defer f.Close()
-			if _, err := f.WriteString(prefset); err != nil {
-				return err
-			}
+		value, err := parsePrefValue(raw)
+		if err != nil {
+			log.Println(err)
+			continue
 		}
+		prefs[name] = value
 	}
-	return nil
+	return prefs
 }
 
+// DeAppifyUserJS reverts the changes UnpackApp made to the profile directory.
+//
+// Deprecated: use Profile.Restore.
 func DeAppifyUserJS(profile string) error {
 	if _, err := os.Stat(profile); err != nil {
 		return nil
 	}
-	extDir := filepath.Join(profile, "extensions")
-	if err := os.Remove(filepath.Join(extDir, "{786c38ae-eac8-41df-ad3b-3c737603bead}.xpi")); err != nil {
-		log.Println(err)
-	} else {
-		log.Println("Removed {786c38ae-eac8-41df-ad3b-3c737603bead}.xpi")
-	}
-	if err := os.Remove(filepath.Join(extDir, "awo@eyedeekay.github.io.xpi")); err != nil {
-		log.Println(err)
-	} else {
-		log.Println("Removed awp@eyedeekay.github.io.xpi")
-	}
-	if err := os.Remove(filepath.Join(profile, "user.js")); err != nil {
-		log.Println(err)
-	} else {
-		log.Println("Removed user-overrides.js")
-	}
-	content, err := ioutil.ReadFile(profile)
-	if err != nil {
-		return err
-	}
-	lines := strings.Split(string(content), "\n")
-	for i, line := range lines {
-		if strings.Contains(line, "toolkit.legacyUserProfileCustomizations.stylesheets\"") {
-			if strings.Contains(line, "false") {
-				return nil
-			} else {
-				line = strings.Replace(line, "true", "false", 1)
-			}
-		}
-		lines[i] = line
-	}
-	out := strings.Join(lines, "\n")
-	if err := ioutil.WriteFile(profile, []byte(out), 0o644); err != nil {
-		return err
-	}
-	return nil
-}
-
-func forceUserChromeCSS(profile string) error {
-	if err := ioutil.WriteFile(profile, UserChrome, 0o644); err != nil {
-		return err
-	}
-	return nil
+	return NewProfile(profile).Restore()
 }
 
 // Run creates a basic instance of the Firefox manager with a default profile directory and
@@ -0,0 +1,205 @@
+package fcw
+
+import "fmt"
+
+// PrefSet is a named group of preferences which serve one purpose.
+type PrefSet struct {
+	Name        string
+	Description string
+	Prefs       map[string]interface{}
+}
+
+// SafeBrowsingLocal keeps Safe Browsing's downloaded block lists, but stops
+// sending details of downloads and visited pages to Google for remote checks.
+var SafeBrowsingLocal = PrefSet{
+	Name:        "safebrowsing-local",
+	Description: "Safe Browsing uses local lists only, without remote lookups",
+	Prefs: map[string]interface{}{
+		"browser.safebrowsing.downloads.remote.enabled":             false,
+		"browser.safebrowsing.downloads.remote.url":                 "",
+		"browser.safebrowsing.provider.google4.dataSharing.enabled": false,
+	},
+}
+
+// SafeBrowsingOff turns Safe Browsing off completely, so the lists are never
+// fetched either.
+var SafeBrowsingOff = PrefSet{
+	Name:        "safebrowsing-off",
+	Description: "Safe Browsing is disabled and its lists are never downloaded",
+	Prefs: map[string]interface{}{
+		"browser.safebrowsing.malware.enabled":          false,
+		"browser.safebrowsing.phishing.enabled":         false,
+		"browser.safebrowsing.blockedURIs.enabled":      false,
+		"browser.safebrowsing.downloads.enabled":        false,
+		"browser.safebrowsing.downloads.remote.enabled": false,
+	},
+}
+
+// ReferrerTrimming sends only the origin, not the full URL, as the referrer
+// to other sites.
+var ReferrerTrimming = PrefSet{
+	Name:        "referrer-trimming",
+	Description: "Cross-origin referrers are trimmed to the origin",
+	Prefs: map[string]interface{}{
+		"network.http.referer.XOriginTrimmingPolicy": 2,
+	},
+}
+
+// ReferrerSameOrigin sends no referrer at all to other sites.
+var ReferrerSameOrigin = PrefSet{
+	Name:        "referrer-same-origin",
+	Description: "Referrers are only sent to the same origin",
+	Prefs: map[string]interface{}{
+		"network.http.referer.XOriginPolicy":         2,
+		"network.http.referer.XOriginTrimmingPolicy": 2,
+	},
+}
+
+// HTTPSOnly upgrades every connection to HTTPS and asks before falling back.
+var HTTPSOnly = PrefSet{
+	Name:        "https-only",
+	Description: "HTTPS-Only mode is enabled in every window",
+	Prefs: map[string]interface{}{
+		"dom.security.https_only_mode":                              true,
+		"dom.security.https_only_mode_send_http_background_request": false,
+	},
+}
+
+// WebRTCNoLocalIP keeps WebRTC working but stops it revealing local network
+// addresses or bypassing a proxy.
+var WebRTCNoLocalIP = PrefSet{
+	Name:        "webrtc-no-local-ip",
+	Description: "WebRTC does not reveal local addresses or bypass the proxy",
+	Prefs: map[string]interface{}{
+		"media.peerconnection.ice.default_address_only":       true,
+		"media.peerconnection.ice.no_host":                    true,
+		"media.peerconnection.ice.proxy_only_if_behind_proxy": true,
+	},
+}
+
+// WebRTCDisabled turns WebRTC off.
+var WebRTCDisabled = PrefSet{
+	Name:        "webrtc-disabled",
+	Description: "WebRTC is disabled",
+	Prefs: map[string]interface{}{
+		"media.peerconnection.enabled": false,
+	},
+}
+
+// FingerprintingResistance makes the browser report the same values as every
+// other resisting browser, and blocks known fingerprinting scripts.
+var FingerprintingResistance = PrefSet{
+	Name:        "fingerprinting-resistance",
+	Description: "Fingerprinting resistance and fingerprinter blocking are enabled",
+	Prefs: map[string]interface{}{
+		"privacy.resistFingerprinting":                      true,
+		"privacy.trackingprotection.enabled":                true,
+		"privacy.trackingprotection.fingerprinting.enabled": true,
+		"privacy.trackingprotection.cryptomining.enabled":   true,
+		"webgl.disabled": true,
+	},
+}
+
+// Letterboxing rounds the content area to common sizes so the window size
+// cannot be used to tell users apart.
+var Letterboxing = PrefSet{
+	Name:        "letterboxing",
+	Description: "The content area is letterboxed to common sizes",
+	Prefs: map[string]interface{}{
+		"privacy.resistFingerprinting.letterboxing": true,
+	},
+}
+
+// SessionRetention keeps nothing on disk from the cache, forms or downloads
+// once the browser is closed.
+var SessionRetention = PrefSet{
+	Name:        "session-retention",
+	Description: "Cache, form and download history is kept for the session only",
+	Prefs: map[string]interface{}{
+		"browser.cache.disk.enable":           false,
+		"browser.formfill.enable":             false,
+		"browser.sessionstore.privacy_level":  2,
+		"privacy.sanitize.sanitizeOnShutdown": true,
+		"privacy.clearOnShutdown.cache":       true,
+		"privacy.clearOnShutdown.downloads":   true,
+		"privacy.clearOnShutdown.formdata":    true,
+	},
+}
+
+// NoRetention additionally keeps no browsing history and clears cookies, site
+// data and sessions on shutdown.
+var NoRetention = PrefSet{
+	Name:        "no-retention",
+	Description: "No history is kept, and cookies, site data and sessions are cleared on shutdown",
+	Prefs: map[string]interface{}{
+		"places.history.enabled":              false,
+		"privacy.sanitize.sanitizeOnShutdown": true,
+		"privacy.clearOnShutdown.history":     true,
+		"privacy.clearOnShutdown.cookies":     true,
+		"privacy.clearOnShutdown.offlineApps": true,
+		"privacy.clearOnShutdown.sessions":    true,
+	},
+}
+
+// HardeningLevel selects a combination of PrefSets applied on top of
+// UserOverrides.
+type HardeningLevel int
+
+const (
+	// HardeningNone changes nothing.
+	HardeningNone HardeningLevel = iota
+	// HardeningBaseline breaks nothing: Safe Browsing stays local, referrers
+	// are trimmed and WebRTC hides local addresses.
+	HardeningBaseline
+	// HardeningStrict adds HTTPS-Only mode and fingerprinting resistance,
+	// and keeps the cache, forms and downloads for the session only. Some
+	// sites will break, including plain HTTP intranet apps and I2P sites,
+	// which HTTPS-Only mode does not exempt.
+	HardeningStrict
+	// HardeningParanoid adds letterboxing, disables WebRTC and Safe
+	// Browsing, sends no cross-origin referrers and retains nothing.
+	HardeningParanoid
+)
+
+var hardeningSets = map[HardeningLevel][]PrefSet{
+	HardeningBaseline: {SafeBrowsingLocal, ReferrerTrimming, WebRTCNoLocalIP},
+	HardeningStrict:   {HTTPSOnly, FingerprintingResistance, SessionRetention},
+	HardeningParanoid: {Letterboxing, WebRTCDisabled, SafeBrowsingOff, ReferrerSameOrigin, NoRetention},
+}
+
+// PrefSets returns the sets of preferences the level applies, in order. Each
+// level includes the sets of the levels below it.
+func (l HardeningLevel) PrefSets() []PrefSet {
+	var sets []PrefSet
+	for level := HardeningBaseline; level <= l; level++ {
+		sets = append(sets, hardeningSets[level]...)
+	}
+	return sets
+}
+
+// Prefs returns the preferences the level applies. Later sets override
+// earlier ones.
+func (l HardeningLevel) Prefs() (map[string]interface{}, error) {
+	if l < HardeningNone || l > HardeningParanoid {
+		return nil, fmt.Errorf("unknown hardening level %d", l)
+	}
+	prefs := map[string]interface{}{}
+	for _, set := range l.PrefSets() {
+		mergePrefs(prefs, set.Prefs)
+	}
+	return prefs, nil
+}
+
+func (l HardeningLevel) String() string {
+	switch l {
+	case HardeningNone:
+		return "none"
+	case HardeningBaseline:
+		return "baseline"
+	case HardeningStrict:
+		return "strict"
+	case HardeningParanoid:
+		return "paranoid"
+	}
+	return fmt.Sprintf("HardeningLevel(%d)", int(l))
+}
@@ -0,0 +1,38 @@
+package fcw
+
+import "testing"
+
+func TestHardeningLevels(t *testing.T) {
+	var previous map[string]interface{}
+	for level := HardeningNone; level <= HardeningParanoid; level++ {
+		prefs, err := level.Prefs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name := range previous {
+			if _, ok := prefs[name]; !ok {
+				t.Errorf("%s drops %s set by the level below", level, name)
+			}
+		}
+		for _, set := range level.PrefSets() {
+			if set.Name == "" || set.Description == "" {
+				t.Errorf("%s has an undocumented set %+v", level, set)
+			}
+			for name, value := range set.Prefs {
+				if _, err := formatPref("user_pref", name, value); err != nil {
+					t.Error(err)
+				}
+			}
+		}
+		previous = prefs
+	}
+	if prefs, _ := HardeningParanoid.Prefs(); prefs["media.peerconnection.enabled"] != false {
+		t.Error("paranoid hardening leaves WebRTC enabled")
+	}
+	if prefs, _ := HardeningBaseline.Prefs(); prefs["dom.security.https_only_mode"] != nil {
+		t.Error("baseline hardening turns on HTTPS-Only mode, which breaks I2P sites")
+	}
+	if _, err := HardeningLevel(7).Prefs(); err == nil {
+		t.Error("unknown hardening level accepted")
+	}
+}
@@ -0,0 +1,72 @@
+package fcw
+
+import (
+	"log"
+	"time"
+)
+
+// DefaultI2PHTTPProxy is the address of the I2P router's HTTP proxy.
+const DefaultI2PHTTPProxy = "127.0.0.1:4444"
+
+// I2PConfig describes how to reach an I2P router.
+type I2PConfig struct {
+	// HTTPProxy is the router's HTTP proxy. It defaults to
+	// DefaultI2PHTTPProxy.
+	HTTPProxy string
+	// Wait is how long I2PFirefox waits for the HTTP proxy to accept
+	// connections before launching. Zero launches without waiting.
+	Wait time.Duration
+}
+
+func (c I2PConfig) httpProxy() string {
+	if c.HTTPProxy == "" {
+		return DefaultI2PHTTPProxy
+	}
+	return c.HTTPProxy
+}
+
+// i2pPrefs make ".i2p" a routable TLD, so that names like "example.i2p" are
+// always loaded rather than handed to a search engine.
+var i2pPrefs = map[string]interface{}{
+	"browser.fixup.domainsuffixwhitelist.i2p":         true,
+	"browser.fixup.alternate.enabled":                 false,
+	"browser.urlbar.dnsResolveSingleWordsAfterSearch": 0,
+	"keyword.enabled":                                 false,
+}
+
+// Apply configures opts to browse I2P through the router's HTTP proxy, with
+// WebRTC, prefetching, speculative connections and other leaks disabled.
+// Local addresses, like the router console, are connected to directly.
+// DNS-over-HTTPS is turned off, so it returns an error if opts.DoH is set.
+func (c I2PConfig) Apply(opts *Options) error {
+	if opts.DoH != nil {
+		return errOverlayDoH
+	}
+	proxy := &ProxyConfig{
+		Mode:    ProxyManual,
+		HTTP:    c.httpProxy(),
+		NoProxy: []string{"localhost", "127.0.0.1"},
+	}
+	if err := proxy.Validate(); err != nil {
+		return err
+	}
+	opts.Proxy = proxy
+	opts.Prefs = overlayPrefs([]map[string]interface{}{leakPrefs, i2pPrefs}, opts.Prefs)
+	return nil
+}
+
+// I2PFirefox sets up a new Firefox instance for browsing I2P, and creates the
+// profile directory if it does not already exist. opts can be used to combine
+// it with any other launch mode, like App.
+func I2PFirefox(userdir string, cfg I2PConfig, opts Options, args ...string) (UI, error) {
+	if err := cfg.Apply(&opts); err != nil {
+		return nil, err
+	}
+	if cfg.Wait > 0 {
+		log.Println("Waiting for I2P HTTP proxy", cfg.httpProxy())
+		if err := WaitForProxy(cfg.httpProxy(), cfg.Wait); err != nil {
+			return nil, err
+		}
+	}
+	return LaunchFirefox(userdir, opts, args...)
+}
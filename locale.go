@@ -0,0 +1,83 @@
+package fcw
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// languageTag matches BCP 47 language tags like "en", "de-CH" or "zh-Hant-TW".
+var languageTag = regexp.MustCompile(`^[A-Za-z]{2,8}(-[A-Za-z0-9]{1,8})*$`)
+
+// LocaleConfig sets the language and time zone a profile presents, regardless
+// of the host's locale.
+type LocaleConfig struct {
+	// UILocale is the language of Firefox's own interface, like "de". The
+	// matching language pack must be installed, or Firefox falls back to
+	// its built-in locale.
+	UILocale string
+	// AcceptLanguages are the languages sites are asked for, in order of
+	// preference.
+	AcceptLanguages []string
+	// Spellcheck is the spellchecker dictionary, like "de-DE".
+	Spellcheck string
+	// Timezone is the IANA time zone, like "Europe/Berlin", passed to
+	// Firefox in the TZ environment variable. It must be in the host's
+	// time zone database, which Firefox reads too. Firefox ignores TZ on
+	// Windows, where it always uses the system's time zone, so setting it
+	// there is an error.
+	Timezone string
+}
+
+// Validate reports whether every locale and the time zone are well formed.
+func (c *LocaleConfig) Validate() error {
+	tags := append([]string{}, c.AcceptLanguages...)
+	for _, tag := range []string{c.UILocale, c.Spellcheck} {
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	for _, tag := range tags {
+		if !languageTag.MatchString(tag) {
+			return fmt.Errorf("invalid language tag %q", tag)
+		}
+	}
+	if c.Timezone != "" {
+		if runtime.GOOS == "windows" {
+			return fmt.Errorf("the time zone %q cannot be applied, Firefox ignores TZ on Windows", c.Timezone)
+		}
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			return fmt.Errorf("invalid time zone %q: %w", c.Timezone, err)
+		}
+	}
+	return nil
+}
+
+// Prefs returns the Firefox preferences which apply the configuration.
+func (c *LocaleConfig) Prefs() (map[string]interface{}, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	prefs := map[string]interface{}{}
+	if c.UILocale != "" {
+		prefs["intl.locale.requested"] = c.UILocale
+		prefs["intl.regional_prefs.use_os_locales"] = false
+	}
+	if len(c.AcceptLanguages) > 0 {
+		prefs["intl.accept_languages"] = strings.Join(c.AcceptLanguages, ",")
+	}
+	if c.Spellcheck != "" {
+		prefs["spellchecker.dictionary"] = c.Spellcheck
+	}
+	return prefs, nil
+}
+
+// env returns the environment variables Firefox needs for the configuration.
+func (c *LocaleConfig) env() []string {
+	if c.Timezone == "" {
+		return nil
+	}
+	return []string{"TZ=" + c.Timezone}
+}
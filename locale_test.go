@@ -0,0 +1,60 @@
+package fcw
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestLocaleConfigPrefs(t *testing.T) {
+	c := &LocaleConfig{
+		UILocale:        "de",
+		AcceptLanguages: []string{"de-DE", "de", "en"},
+		Spellcheck:      "de-DE",
+	}
+	prefs, err := c.Prefs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"intl.locale.requested":              "de",
+		"intl.regional_prefs.use_os_locales": false,
+		"intl.accept_languages":              "de-DE,de,en",
+		"spellchecker.dictionary":            "de-DE",
+	}
+	if !reflect.DeepEqual(prefs, want) {
+		t.Errorf("Prefs = %v, want %v", prefs, want)
+	}
+	if prefs, _ := (&LocaleConfig{}).Prefs(); len(prefs) != 0 {
+		t.Errorf("empty LocaleConfig sets %v", prefs)
+	}
+	for _, bad := range []*LocaleConfig{
+		{UILocale: "de_DE"},
+		{AcceptLanguages: []string{"en", "x"}},
+		{Spellcheck: "de DE"},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%+v accepted", bad)
+		}
+	}
+}
+
+func TestLocaleConfigTimezone(t *testing.T) {
+	if err := (&LocaleConfig{Timezone: "Mars/Olympus_Mons"}).Validate(); err == nil {
+		t.Error("unknown time zone accepted")
+	}
+	c := &LocaleConfig{Timezone: "UTC"}
+	err := c.Validate()
+	if runtime.GOOS == "windows" {
+		if err == nil {
+			t.Error("time zone accepted on Windows, where Firefox ignores TZ")
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env := c.env(); !reflect.DeepEqual(env, []string{"TZ=UTC"}) {
+		t.Errorf("env = %v", env)
+	}
+}
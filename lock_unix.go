@@ -0,0 +1,61 @@
+//go:build !windows
+// +build !windows
+
+package fcw
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// locked reports whether a running Firefox is using the profile. On Linux,
+// Firefox keeps a "lock" symlink in the profile pointing at "address:+pid"
+// while it runs, and leaves it behind when it crashes, so the process is
+// checked too. On macOS it only holds an fcntl lock on .parentlock, which
+// goes away with the process.
+func (p *Profile) locked() (bool, error) {
+	if locked, err := p.symlinkLocked(); locked || err != nil {
+		return locked, err
+	}
+	return p.parentLocked()
+}
+
+func (p *Profile) symlinkLocked() (bool, error) {
+	target, err := os.Readlink(filepath.Join(p.dir, "lock"))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	i := strings.LastIndex(target, ":+")
+	if i < 0 {
+		return true, nil
+	}
+	pid, err := strconv.Atoi(target[i+2:])
+	if err != nil {
+		return true, nil
+	}
+	// Signal 0 only checks that the process exists. EPERM means it belongs
+	// to someone else, but exists.
+	err = syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM, nil
+}
+
+func (p *Profile) parentLocked() (bool, error) {
+	f, err := os.Open(filepath.Join(p.dir, ".parentlock"))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	lk := syscall.Flock_t{Type: syscall.F_WRLCK, Whence: io.SeekStart}
+	if err := syscall.FcntlFlock(f.Fd(), syscall.F_GETLK, &lk); err != nil {
+		return false, err
+	}
+	return lk.Type != syscall.F_UNLCK, nil
+}
@@ -0,0 +1,69 @@
+//go:build !windows
+// +build !windows
+
+package fcw
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// TestParentLockHelper holds an fcntl lock on .parentlock the way Firefox
+// does, until its standard input is closed. It only runs as a subprocess of
+// TestParentLock.
+func TestParentLockHelper(t *testing.T) {
+	dir := os.Getenv("FPW_LOCK_PROFILE")
+	if dir == "" {
+		t.Skip("only runs as a subprocess")
+	}
+	f, err := os.OpenFile(filepath.Join(dir, ".parentlock"), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lk := syscall.Flock_t{Type: syscall.F_WRLCK, Whence: io.SeekStart}
+	if err := syscall.FcntlFlock(f.Fd(), syscall.F_SETLK, &lk); err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout.WriteString("locked\n")
+	io.Copy(io.Discard, os.Stdin)
+}
+
+func TestParentLock(t *testing.T) {
+	dir := t.TempDir()
+	p := NewProfile(dir)
+	cmd := exec.Command(os.Args[0], "-test.run=^TestParentLockHelper$")
+	cmd.Env = append(os.Environ(), "FPW_LOCK_PROFILE="+dir)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if line, err := bufio.NewReader(stdout).ReadString('\n'); line != "locked\n" {
+		stdin.Close()
+		cmd.Wait()
+		t.Fatalf("helper did not lock .parentlock: %q, %v", line, err)
+	}
+	if locked, err := p.Locked(); err != nil || !locked {
+		t.Errorf("Locked() = %v, %v while .parentlock is locked", locked, err)
+	}
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	// The file is left behind, as after a crash, but no longer locked.
+	if locked, err := p.Locked(); err != nil || locked {
+		t.Errorf("Locked() = %v, %v after the lock was released", locked, err)
+	}
+}
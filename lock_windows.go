@@ -0,0 +1,23 @@
+//go:build windows
+// +build windows
+
+package fcw
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// locked reports whether a running Firefox is using the profile. Firefox
+// keeps parent.lock in the profile open without sharing while it runs, so it
+// cannot be opened then.
+func (p *Profile) locked() (bool, error) {
+	f, err := os.OpenFile(filepath.Join(p.dir, "parent.lock"), os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return true, nil
+	}
+	f.Close()
+	return false, nil
+}
@@ -0,0 +1,59 @@
+package fcw
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/http"
+)
+
+// loopbackServer is an HTTP server on a random port of the loopback
+// interface, which lives as long as a browser. Its token is a random path
+// prefix, so that pages cannot guess its URLs.
+type loopbackServer struct {
+	listener net.Listener
+	server   *http.Server
+	token    string
+}
+
+func listenLoopback() (*loopbackServer, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	return &loopbackServer{
+		listener: listener,
+		token:    "/" + hex.EncodeToString(token),
+	}, nil
+}
+
+// serve starts handling requests with h.
+func (s *loopbackServer) serve(h http.Handler) {
+	s.server = &http.Server{Handler: h}
+	go func() {
+		if err := s.server.Serve(s.listener); err != nil && err != http.ErrServerClosed {
+			log.Println(err)
+		}
+	}()
+}
+
+func (s *loopbackServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+// url returns the address of path under the server's token.
+func (s *loopbackServer) url(path string) string {
+	return "http://" + s.addr() + s.token + path
+}
+
+func (s *loopbackServer) close() error {
+	if s.server == nil {
+		return s.listener.Close()
+	}
+	return s.server.Close()
+}
@@ -0,0 +1,263 @@
+package fcw
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// manifestName is the file in the profile directory which holds the Manifest
+// of changes made by this package.
+const manifestName = "fpw-manifest.json"
+
+// Manifest records every file and preference this package added to or changed
+// in a profile, together with what was there before, so that Restore can put
+// the profile back exactly the way it found it.
+type Manifest struct {
+	Files []FileChange `json:"files"`
+	Prefs []PrefChange `json:"prefs"`
+}
+
+// FileChange records a file or directory which was created or overwritten.
+type FileChange struct {
+	// Path is relative to the profile directory.
+	Path string `json:"path"`
+	// Dir is set when the change created a directory.
+	Dir bool `json:"dir,omitempty"`
+	// Existed is set when there was a file at Path before the change, in which
+	// case Content and Mode hold its original content and permissions.
+	Existed bool        `json:"existed"`
+	Content []byte      `json:"content,omitempty"`
+	Mode    os.FileMode `json:"mode,omitempty"`
+}
+
+// PrefChange records a preference which was set in a preference file such as
+// user.js or prefs.js.
+type PrefChange struct {
+	// File is relative to the profile directory.
+	File string `json:"file"`
+	Name string `json:"name"`
+	// Line is the statement which set the preference before the change, or
+	// empty if it was not set.
+	Line string `json:"line,omitempty"`
+	// Created is set when the change created File.
+	Created bool `json:"created,omitempty"`
+}
+
+func (m *Manifest) hasFile(rel string) bool {
+	for _, f := range m.Files {
+		if f.Path == rel {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manifest) hasPref(file, name string) bool {
+	for _, p := range m.Prefs {
+		if p.File == file && p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Profile) manifestPath() string {
+	return filepath.Join(p.dir, manifestName)
+}
+
+// Manifest returns the changes currently recorded for the profile. It returns
+// an empty Manifest if nothing has been recorded.
+func (p *Profile) Manifest() (*Manifest, error) {
+	m := &Manifest{}
+	content, err := os.ReadFile(p.manifestPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(content, m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", p.manifestPath(), err)
+	}
+	return m, nil
+}
+
+func (p *Profile) saveManifest(m *Manifest) error {
+	if len(m.Files) == 0 && len(m.Prefs) == 0 {
+		return nil
+	}
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p.manifestPath(), content, 0o644)
+}
+
+// record runs fn with every change made through the profile's helpers noted in
+// its manifest. Changes recorded by an earlier run which was never restored
+// keep their original state, so Restore always goes back to the profile as it
+// was before this package first touched it.
+func (p *Profile) record(fn func() error) error {
+	m, err := p.Manifest()
+	if err != nil {
+		return err
+	}
+	p.manifest = m
+	defer func() {
+		p.manifest = nil
+	}()
+	ferr := fn()
+	if err := p.saveManifest(m); err != nil {
+		return err
+	}
+	return ferr
+}
+
+// mkdir creates the directory rel and any missing parents inside the profile.
+func (p *Profile) mkdir(rel string) error {
+	var missing []string
+	for dir := rel; dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(p.dir, dir)); os.IsNotExist(err) {
+			missing = append([]string{dir}, missing...)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(p.dir, rel), 0o755); err != nil {
+		return err
+	}
+	if p.manifest != nil {
+		for _, dir := range missing {
+			if !p.manifest.hasFile(dir) {
+				p.manifest.Files = append(p.manifest.Files, FileChange{Path: dir, Dir: true})
+			}
+		}
+	}
+	return nil
+}
+
+// writeFile replaces the file rel inside the profile with data.
+func (p *Profile) writeFile(rel string, data []byte, perm os.FileMode) error {
+	path := filepath.Join(p.dir, rel)
+	if p.manifest != nil && !p.manifest.hasFile(rel) {
+		change := FileChange{Path: rel}
+		if info, err := os.Stat(path); err == nil {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			change.Existed = true
+			change.Content = content
+			change.Mode = info.Mode().Perm()
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		p.manifest.Files = append(p.manifest.Files, change)
+	}
+	return os.WriteFile(path, data, perm)
+}
+
+// setPrefsIn sets prefs in the preference file rel inside the profile.
+func (p *Profile) setPrefsIn(rel string, prefs map[string]interface{}) error {
+	if len(prefs) == 0 {
+		return nil
+	}
+	path := filepath.Join(p.dir, rel)
+	_, statErr := os.Stat(path)
+	pf, err := loadPrefFile(path)
+	if err != nil {
+		return err
+	}
+	// Firefox copies every user.js value into prefs.js when it exits, so
+	// prefs.js has to be reverted too.
+	var prefsJS *prefFile
+	if rel == "user.js" && p.manifest != nil {
+		if prefsJS, err = loadPrefFile(filepath.Join(p.dir, "prefs.js")); err != nil {
+			return err
+		}
+	}
+	for _, name := range sortedPrefNames(prefs) {
+		if p.manifest != nil && !p.manifest.hasPref(rel, name) {
+			change := PrefChange{File: rel, Name: name, Created: os.IsNotExist(statErr)}
+			if i := pf.find(name); i >= 0 {
+				change.Line = pf.lines[i]
+			}
+			p.manifest.Prefs = append(p.manifest.Prefs, change)
+		}
+		if prefsJS != nil && !p.manifest.hasPref("prefs.js", name) {
+			change := PrefChange{File: "prefs.js", Name: name}
+			if i := prefsJS.find(name); i >= 0 {
+				change.Line = prefsJS.lines[i]
+			}
+			p.manifest.Prefs = append(p.manifest.Prefs, change)
+		}
+		if err := pf.set(name, prefs[name]); err != nil {
+			return err
+		}
+	}
+	return pf.save()
+}
+
+// Restore reverts every change recorded in the profile's manifest and removes
+// the manifest. Preferences are reverted one at a time, so anything else in
+// user.js or prefs.js, including changes Firefox made while it was running, is
+// left alone. Preferences set in user.js are reverted in prefs.js too, where
+// Firefox copies them when it exits. Files this package created are removed, files it overwrote get
+// their original content back, and directories it created are removed if they
+// are empty.
+func (p *Profile) Restore() error {
+	m, err := p.Manifest()
+	if err != nil {
+		return err
+	}
+	prefFiles := map[string]*prefFile{}
+	created := map[string]bool{}
+	for _, change := range m.Prefs {
+		pf, ok := prefFiles[change.File]
+		if !ok {
+			if pf, err = loadPrefFile(filepath.Join(p.dir, change.File)); err != nil {
+				return err
+			}
+			prefFiles[change.File] = pf
+		}
+		if change.Created {
+			created[change.File] = true
+		}
+		pf.revert(change.Name, change.Line)
+	}
+	for rel, pf := range prefFiles {
+		if created[rel] && !pf.hasPrefs() {
+			if err := os.Remove(pf.path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if err := pf.save(); err != nil {
+			return err
+		}
+	}
+	for i := len(m.Files) - 1; i >= 0; i-- {
+		change := m.Files[i]
+		path := filepath.Join(p.dir, change.Path)
+		switch {
+		case change.Dir:
+			if entries, err := os.ReadDir(path); err == nil && len(entries) == 0 {
+				if err := os.Remove(path); err != nil {
+					return err
+				}
+			}
+		case change.Existed:
+			if err := os.WriteFile(path, change.Content, change.Mode); err != nil {
+				return err
+			}
+		default:
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	if err := os.Remove(p.manifestPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package fcw
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnpackAppRestore(t *testing.T) {
+	dir := t.TempDir()
+	original := map[string]string{
+		"user.js":  "// mine\nuser_pref(\"browser.startup.page\", 3);\nuser_pref(\"toolkit.telemetry.enabled\", true);\n",
+		"prefs.js": "user_pref(\"toolkit.legacyUserProfileCustomizations.stylesheets\", false);\n",
+	}
+	for name, content := range original {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := UnpackApp(dir, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v, _, _ := NewProfile(dir).GetPref("toolkit.legacyUserProfileCustomizations.stylesheets"); v != true {
+		t.Errorf("stylesheets pref = %v after UnpackApp", v)
+	}
+	// Firefox adds its own preferences to prefs.js while it runs.
+	f, err := os.OpenFile(filepath.Join(dir, "prefs.js"), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("user_pref(\"browser.migration.version\", 140);\n")
+	f.Close()
+	original["prefs.js"] += "user_pref(\"browser.migration.version\", 140);\n"
+
+	if err := NewProfile(dir).Restore(); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range original {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s =\n%s\nwant\n%s", name, got, want)
+		}
+	}
+	for _, name := range []string{"chrome", "extensions", manifestName} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after Restore", name)
+		}
+	}
+}
+
+func TestRestorePrefsJS(t *testing.T) {
+	dir := t.TempDir()
+	original := "user_pref(\"network.proxy.type\", 0);\nuser_pref(\"browser.migration.version\", 140);\n"
+	if err := os.WriteFile(filepath.Join(dir, "prefs.js"), []byte(original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := NewProfile(dir)
+	err := p.record(func() error {
+		return p.SetPrefs(map[string]interface{}{
+			"network.proxy.type":            2,
+			"network.proxy.autoconfig_url":  "http://127.0.0.1:40123/proxy.pac",
+			"network.proxy.failover_direct": false,
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Firefox saves the user.js values into prefs.js when it exits.
+	pf, err := loadPrefFile(filepath.Join(dir, "prefs.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf.set("network.proxy.type", 2)
+	pf.set("network.proxy.autoconfig_url", "http://127.0.0.1:40123/proxy.pac")
+	pf.set("network.proxy.failover_direct", false)
+	if err := pf.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Restore(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "prefs.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != original {
+		t.Errorf("prefs.js =\n%s\nwant\n%s", got, original)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "user.js")); !os.IsNotExist(err) {
+		t.Error("user.js still exists after Restore")
+	}
+}
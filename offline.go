@@ -0,0 +1,52 @@
+package fcw
+
+// OfflineConfig describes an air-gapped profile, which can only reach the
+// hosts it is explicitly allowed to.
+type OfflineConfig struct {
+	// Allow lists the hosts the browser may connect to, like the app's
+	// local backend. An entry also matches every subdomain of the host, and
+	// an entry with a port, like "localhost:8080", only matches that port.
+	// Local addresses are only reachable if they are listed too.
+	Allow []string
+	// OnBlocked is called with the target of every blocked request. Blocked
+	// requests are logged either way.
+	OnBlocked func(target string)
+}
+
+// offlinePrefs stop the browser's own background traffic which noUpdatePrefs
+// and SafeBrowsingOff leave alone: add-on metadata, region lookups,
+// connectivity checks, prefetching and similar.
+var offlinePrefs = map[string]interface{}{
+	"extensions.getAddons.cache.enabled":              false,
+	"browser.region.network.url":                      "",
+	"network.captive-portal-service.enabled":          false,
+	"network.connectivity-service.enabled":            false,
+	"network.prefetch-next":                           false,
+	"network.dns.disablePrefetch":                     true,
+	"network.predictor.enabled":                       false,
+	"network.http.speculative-parallel-limit":         0,
+	"browser.urlbar.speculativeConnect.enabled":       false,
+	"browser.safebrowsing.provider.google4.updateURL": "",
+	"browser.safebrowsing.provider.google.updateURL":  "",
+	// Send local addresses through the proxy auto-config file as well, so
+	// they can be blocked unless they are allowed.
+	"network.proxy.allow_hijacking_localhost": true,
+}
+
+// pacConfig returns the proxy auto-config which allows only the configured
+// hosts, and the loopback servers at local.
+func (c *OfflineConfig) pacConfig(local ...string) PACConfig {
+	return PACConfig{
+		Direct:     append(append([]string{}, c.Allow...), local...),
+		Default:    RouteBlock,
+		NoOverlays: true,
+		OnBlocked:  c.OnBlocked,
+	}
+}
+
+// prefs returns the preferences which turn the browser's own background
+// traffic off, and WebRTC, whose UDP traffic does not go through the proxy
+// auto-config file.
+func (c *OfflineConfig) prefs() map[string]interface{} {
+	return overlayPrefs([]map[string]interface{}{SafeBrowsingOff.Prefs, noUpdatePrefs, WebRTCDisabled.Prefs, offlinePrefs}, nil)
+}
@@ -0,0 +1,41 @@
+package fcw
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOfflineConfig(t *testing.T) {
+	c := &OfflineConfig{Allow: []string{"localhost:8080", "Intranet.example.com"}}
+	prefs := c.prefs()
+	for name, value := range map[string]interface{}{
+		"media.peerconnection.enabled":            false,
+		"network.proxy.allow_hijacking_localhost": true,
+		"browser.safebrowsing.malware.enabled":    false,
+		"app.update.auto":                         false,
+		"network.prefetch-next":                   false,
+	} {
+		if prefs[name] != value {
+			t.Errorf("%s = %v, want %v", name, prefs[name], value)
+		}
+	}
+	pac := c.pacConfig("127.0.0.1:40000")
+	script, err := pac.Script("127.0.0.1:40001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`var direct = [{host: "localhost", port: "8080"}, {host: "intranet.example.com", port: ""}, {host: "127.0.0.1", port: "40000"}];`,
+		`return "PROXY 127.0.0.1:40001";`,
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("PAC script does not contain %s:\n%s", want, script)
+		}
+	}
+	// .i2p and .onion hosts are blocked like everything else.
+	for _, overlay := range []string{"127.0.0.1:4444", "127.0.0.1:9050"} {
+		if strings.Contains(script, overlay) {
+			t.Errorf("PAC script routes to %s:\n%s", overlay, script)
+		}
+	}
+}
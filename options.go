@@ -0,0 +1,412 @@
+package fcw
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Options describes how a profile is prepared before Firefox is launched with
+// it by LaunchFirefox.
+type Options struct {
+	// Private opens the browser in a private window.
+	Private bool
+	// Template is a profile directory, or a tar.gz or zip archive of one,
+	// which a new or empty profile is cloned from before anything else is
+	// set up, see Profile.CloneFrom. Profiles with files in them are used
+	// as they are.
+	Template string
+	// Downgrade is what to do when the profile was last used by a newer
+	// Firefox than the one being launched.
+	Downgrade DowngradePolicy
+	// App turns the profile into a WebApp-Viewer, see UnpackApp.
+	App bool
+	// Offline installs the "actually work offline" extension. It only
+	// applies in App mode. See Airgap for blocking the network outright.
+	Offline bool
+	// Proxy configures the proxy the profile sends its traffic through. Nil
+	// leaves the profile's proxy settings alone.
+	Proxy *ProxyConfig
+	// PAC starts a local server with a proxy auto-config file splitting
+	// traffic between clearnet, I2P and Tor, and points the profile at it.
+	// The server runs until the UI is closed. It cannot be combined with
+	// Proxy.
+	PAC *PACConfig
+	// Scope keeps the browser on the app: top-level navigations to URLs
+	// which do not start with one of these origins or URL prefixes are
+	// opened in the system's default browser instead. It is enforced by a
+	// generated, unsigned extension, so it needs a Firefox build which can
+	// load those, like ESR, Developer Edition, Nightly or LibreWolf.
+	// LaunchFirefox returns an error wrapping ErrUnsignedExtensions rather
+	// than launching a release or beta build of Firefox without it.
+	Scope []string
+	// Airgap blocks every network connection except to the allowed hosts,
+	// and turns off the browser's own background traffic. It cannot be
+	// combined with Proxy or PAC.
+	Airgap *OfflineConfig
+	// DisableUpdates stops Firefox updating itself or its add-ons, polling
+	// remote settings, scheduling background tasks and running the default
+	// browser agent. Besides setting preferences, it writes
+	// distribution/policies.json when Firefox is a portable install found
+	// by PortablePath. Other installs are left alone.
+	DisableUpdates bool
+	// Policies are merged into distribution/policies.json in Firefox's
+	// installation directory before launch. The launch fails if they are
+	// invalid or the directory is not writable.
+	Policies *Policies
+	// LockedPrefs are locked with an autoconfig file in Firefox's
+	// installation directory, so they cannot be changed in about:config.
+	// The lock lasts until Close, which puts back the installation's
+	// previous autoconfig. While the browser runs it affects every profile
+	// used with the installation, so it is meant for bundled browsers. The
+	// launch fails if the directory is not writable. Use Autoconfig.Write
+	// for a lock which outlives the launch.
+	LockedPrefs map[string]interface{}
+	// LockProxy locks the proxy preferences set by Proxy, PAC, Airgap or a
+	// preset the same way as LockedPrefs, so the user cannot turn the proxy
+	// off. It lasts until Close, like LockedPrefs.
+	LockProxy bool
+	// Startup sets the home page, what is shown on startup and what new tabs
+	// show. A custom new tab page is set by a generated, unsigned extension,
+	// with the same limits as Scope.
+	Startup *StartupConfig
+	// Downloads sets where downloads go and what Firefox does with them.
+	Downloads *DownloadConfig
+	// Locale sets the interface language, the languages sites are asked
+	// for, the spellchecker dictionary and the time zone.
+	Locale *LocaleConfig
+	// Hardening applies one of the privacy hardening levels.
+	Hardening HardeningLevel
+	// DoH configures DNS-over-HTTPS. Nil leaves the profile's DNS settings
+	// alone. The I2P and Tor presets turn it off, and cannot be combined
+	// with it.
+	DoH *DoHConfig
+	// Prefs are merged into the profile's user.js before launch, after and
+	// on top of the preferences the other options produce. Values must be
+	// bools, strings or integers.
+	Prefs map[string]interface{}
+	// Transient reverts every change LaunchFirefox makes to the profile when
+	// the UI is closed, see Profile.Restore. Otherwise Prefs and the
+	// preferences the other options produce are kept, and only App mode and
+	// what only works for this launch, like the generated extensions and the
+	// address of a local proxy auto-config server, are reverted.
+	Transient bool
+}
+
+// prefs returns every preference the options set.
+func (o *Options) prefs() (map[string]interface{}, error) {
+	prefs, err := o.Hardening.Prefs()
+	if err != nil {
+		return nil, err
+	}
+	if o.DoH != nil {
+		dohPrefs, err := o.DoH.Prefs()
+		if err != nil {
+			return nil, err
+		}
+		mergePrefs(prefs, dohPrefs)
+	}
+	if o.DisableUpdates {
+		mergePrefs(prefs, noUpdatePrefs)
+	}
+	if o.Startup != nil {
+		startupPrefs, err := o.Startup.Prefs()
+		if err != nil {
+			return nil, err
+		}
+		mergePrefs(prefs, startupPrefs)
+	}
+	if o.Downloads != nil {
+		downloadPrefs, err := o.Downloads.Prefs()
+		if err != nil {
+			return nil, err
+		}
+		mergePrefs(prefs, downloadPrefs)
+	}
+	if o.Locale != nil {
+		localePrefs, err := o.Locale.Prefs()
+		if err != nil {
+			return nil, err
+		}
+		mergePrefs(prefs, localePrefs)
+	}
+	if o.Airgap != nil {
+		mergePrefs(prefs, o.Airgap.prefs())
+	}
+	if o.Proxy != nil {
+		proxyPrefs, err := o.Proxy.Prefs()
+		if err != nil {
+			return nil, err
+		}
+		mergePrefs(prefs, proxyPrefs)
+	}
+	mergePrefs(prefs, o.Prefs)
+	return prefs, nil
+}
+
+func mergePrefs(dst, src map[string]interface{}) {
+	for name, value := range src {
+		dst[name] = value
+	}
+}
+
+// LaunchFirefox sets up a new Firefox instance configured by opts, and creates
+// the profile directory if it does not already exist.
+func LaunchFirefox(userdir string, opts Options, args ...string) (UI, error) {
+	l, err := opts.prepare()
+	if err != nil {
+		return nil, err
+	}
+	if len(l.scope) > 0 {
+		if err := checkUnsignedExtensions(FirefoxExecutable()); err != nil {
+			l.close()
+			return nil, fmt.Errorf("Scope cannot be enforced: %w", err)
+		}
+	}
+	cleanedArgs := cleanArgs(opts.Private, args)
+	log.Println("Args", cleanedArgs)
+	userdir, err = filepath.Abs(userdir)
+	if err != nil {
+		l.close()
+		return nil, err
+	}
+	p := NewProfile(userdir)
+	if err := p.Create(); err != nil {
+		l.close()
+		return nil, err
+	}
+	if opts.Template != "" {
+		empty, err := p.empty()
+		if err == nil && empty {
+			log.Println("Cloning profile from", opts.Template)
+			err = p.CloneFrom(opts.Template)
+		}
+		if err != nil {
+			l.close()
+			return nil, err
+		}
+	}
+	// The version check comes after cloning, as the template may have been
+	// used by a newer Firefox too.
+	downgradeArgs, err := p.handleDowngrade(opts.Downgrade, FirefoxExecutable(), opts.Template)
+	if err != nil {
+		l.close()
+		return nil, err
+	}
+	cleanedArgs = append(cleanedArgs, downgradeArgs...)
+	fail := func(err error) (UI, error) {
+		l.close()
+		if rerr := p.Restore(); rerr != nil {
+			log.Println(rerr)
+		}
+		return nil, err
+	}
+	if opts.App {
+		log.Println("Unpacking App" + userdir)
+		userdir, err = UnpackApp(userdir, opts.Offline)
+		if err != nil {
+			return fail(err)
+		}
+		log.Println("Unpacked App" + userdir)
+	}
+	persistent := func() error {
+		if opts.Downloads != nil {
+			if err := p.setDownloadActions(opts.Downloads.Actions); err != nil {
+				return err
+			}
+		}
+		return p.SetPrefs(l.prefs)
+	}
+	if err := p.record(func() error {
+		for _, ext := range l.extensions {
+			if err := p.installExtension(ext); err != nil {
+				return err
+			}
+		}
+		if err := p.SetPrefs(l.transient); err != nil {
+			return err
+		}
+		if opts.Transient {
+			return persistent()
+		}
+		return nil
+	}); err != nil {
+		return fail(err)
+	}
+	if !opts.Transient {
+		if err := persistent(); err != nil {
+			return fail(err)
+		}
+	}
+	if opts.Policies != nil || l.locked != nil {
+		dir, err := InstallDir(FirefoxExecutable())
+		if err != nil {
+			return fail(err)
+		}
+		if opts.Policies != nil {
+			if err := opts.Policies.Merge(dir); err != nil {
+				return fail(err)
+			}
+		}
+		if l.locked != nil {
+			// The locks are for this launch only, so other profiles
+			// must not be left with them.
+			restore, err := (&Autoconfig{Locked: l.locked}).writeUntilClosed(dir)
+			if err != nil {
+				return fail(err)
+			}
+			l.closers = append(l.closers, restore)
+		}
+	}
+	if exe := FirefoxExecutable(); opts.DisableUpdates && portable(exe) {
+		if err := DisableUpdates(exe); err != nil {
+			log.Println("Could not write update policies, relying on preferences:", err)
+		}
+	}
+	ui, err := newUI("", userdir, 800, 600, l.env, cleanedArgs...)
+	if err != nil {
+		return fail(err)
+	}
+	ui.closers = l.closers
+	return ui, nil
+}
+
+// launch holds what LaunchFirefox sets up around a profile: the preferences
+// and extensions to install, and the local servers which run alongside the
+// browser.
+type launch struct {
+	prefs      map[string]interface{}
+	transient  map[string]interface{}
+	scope      []string
+	locked     map[string]interface{}
+	env        []string
+	extensions []*webExtension
+	closers    []func() error
+}
+
+func (l *launch) close() {
+	for _, closer := range l.closers {
+		if err := closer(); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// prepare starts the local servers the options need and works out the
+// preferences and extensions for the profile.
+func (o Options) prepare() (*launch, error) {
+	l := &launch{}
+	fail := func(err error) (*launch, error) {
+		l.close()
+		return nil, err
+	}
+	routing := 0
+	for _, set := range []bool{o.Proxy != nil, o.PAC != nil, o.Airgap != nil} {
+		if set {
+			routing++
+		}
+	}
+	if routing > 1 {
+		return nil, fmt.Errorf("only one of the Proxy, PAC and Airgap options can be set")
+	}
+	var local []string
+	if len(o.Scope) > 0 {
+		prefixes, err := scopePrefixes(o.Scope)
+		if err != nil {
+			return fail(err)
+		}
+		handoff, err := startScopeHandoff()
+		if err != nil {
+			return fail(err)
+		}
+		l.scope = prefixes
+		l.closers = append(l.closers, handoff.close)
+		local = append(local, handoff.addr())
+		ext, err := scopeExtension(prefixes, handoff.url("/open"))
+		if err != nil {
+			return fail(err)
+		}
+		l.extensions = append(l.extensions, ext)
+	}
+	if o.Startup != nil && o.Startup.Validate() == nil {
+		if page := o.Startup.newTabPage(); page != "" {
+			ext, err := newTabExtension(page)
+			if err != nil {
+				return fail(err)
+			}
+			l.extensions = append(l.extensions, ext)
+		}
+	}
+	if o.Downloads != nil && o.Downloads.Dir != "" {
+		if err := os.MkdirAll(o.Downloads.Dir, 0o755); err != nil {
+			return fail(err)
+		}
+		if o.Downloads.OnComplete != nil {
+			watcher, err := WatchDownloads(o.Downloads.Dir, o.Downloads.OnComplete)
+			if err != nil {
+				return fail(err)
+			}
+			l.closers = append(l.closers, watcher.Close)
+		}
+	}
+	if o.Airgap != nil {
+		pac := o.Airgap.pacConfig(local...)
+		o.PAC = &pac
+	}
+	if o.PAC != nil {
+		pac, err := StartPACServer(*o.PAC)
+		if err != nil {
+			return fail(err)
+		}
+		log.Println("Serving proxy auto-config at", pac.URL())
+		l.closers = append(l.closers, pac.Close)
+		o.Proxy = pac.ProxyConfig()
+		// The server's address changes with every launch, so pointing
+		// the profile at it only lasts until Close.
+		if l.transient, err = o.Proxy.Prefs(); err != nil {
+			return fail(err)
+		}
+	}
+	prefs, err := o.prefs()
+	if err != nil {
+		return fail(err)
+	}
+	l.prefs = prefs
+	if o.Locale != nil {
+		l.env = o.Locale.env()
+	}
+	if len(o.LockedPrefs) > 0 || o.LockProxy {
+		l.locked = map[string]interface{}{}
+		if o.LockProxy {
+			for name, value := range prefs {
+				if strings.HasPrefix(name, "network.proxy.") {
+					l.locked[name] = value
+				}
+			}
+		}
+		mergePrefs(l.locked, o.LockedPrefs)
+	}
+	for name := range l.transient {
+		l.transient[name] = prefs[name]
+		delete(l.prefs, name)
+	}
+	return l, nil
+}
+
+// cleanArgs drops blank arguments and makes sure "--private-window" is passed
+// exactly when private is set, and only once.
+func cleanArgs(private bool, args []string) []string {
+	var cleanedArgs []string
+	if private {
+		cleanedArgs = append(cleanedArgs, "--private-window")
+	}
+	for _, arg := range args {
+		if arg == "" || arg == "--private-window" {
+			continue
+		}
+		cleanedArgs = append(cleanedArgs, arg)
+	}
+	return cleanedArgs
+}
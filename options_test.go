@@ -0,0 +1,78 @@
+package fcw
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestCleanArgs(t *testing.T) {
+	for _, c := range []struct {
+		private bool
+		args    []string
+		want    []string
+	}{
+		{false, []string{"", "https://example.com"}, []string{"https://example.com"}},
+		{false, []string{"--private-window", "https://example.com"}, []string{"https://example.com"}},
+		{true, []string{"https://example.com"}, []string{"--private-window", "https://example.com"}},
+		// Arguments after a duplicate "--private-window" are kept.
+		{true, []string{"--private-window", "--kiosk", "https://example.com"}, []string{"--private-window", "--kiosk", "https://example.com"}},
+	} {
+		if got := cleanArgs(c.private, c.args); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("cleanArgs(%v, %q) = %q, want %q", c.private, c.args, got, c.want)
+		}
+	}
+}
+
+// fakeFirefox replaces FirefoxExecutable with a script which exits at once.
+func fakeFirefox(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell script as the Firefox executable")
+	}
+	exe := filepath.Join(t.TempDir(), "firefox")
+	if err := os.WriteFile(exe, []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	saved := FirefoxExecutable
+	FirefoxExecutable = func() string { return exe }
+	t.Cleanup(func() { FirefoxExecutable = saved })
+}
+
+func TestLaunchKeepsPrefs(t *testing.T) {
+	fakeFirefox(t)
+	dir := t.TempDir()
+	p := NewProfile(dir)
+	if err := p.SetPref("media.autoplay.default", 5); err != nil {
+		t.Fatal(err)
+	}
+	for _, transient := range []bool{false, true} {
+		ui, err := LaunchFirefox(dir, Options{
+			App:       true,
+			PAC:       &PACConfig{Default: RouteBlock},
+			Prefs:     map[string]interface{}{"browser.startup.page": 3},
+			Transient: transient,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ui.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if v, _, _ := p.GetPref("media.autoplay.default"); v != 5 {
+			t.Errorf("Transient %v: media.autoplay.default = %v after Close, want 5", transient, v)
+		}
+		v, ok, _ := p.GetPref("browser.startup.page")
+		if want := !transient; ok != want {
+			t.Errorf("Transient %v: browser.startup.page kept = %v (%v), want %v", transient, ok, v, want)
+		}
+		// App mode and the address of the stopped PAC server are reverted.
+		for _, name := range []string{"toolkit.legacyUserProfileCustomizations.stylesheets", "network.proxy.autoconfig_url"} {
+			if _, ok, _ := p.GetPref(name); ok {
+				t.Errorf("Transient %v: %s kept after Close", transient, name)
+			}
+		}
+		p.DeletePref("browser.startup.page")
+	}
+}
@@ -0,0 +1,72 @@
+package fcw
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ErrProxyUnreachable is returned when an overlay network's proxy is not
+// accepting connections, so launching would either fail or leak.
+var ErrProxyUnreachable = errors.New("proxy is not accepting connections")
+
+// leakPrefs close the ways a page can make connections or DNS lookups which
+// do not go through the configured proxy, or reveal local addresses.
+var leakPrefs = map[string]interface{}{
+	// WebRTC
+	"media.peerconnection.enabled":                        false,
+	"media.peerconnection.ice.default_address_only":       true,
+	"media.peerconnection.ice.no_host":                    true,
+	"media.peerconnection.ice.proxy_only_if_behind_proxy": true,
+	// Prefetching and speculative connections
+	"network.prefetch-next":                     false,
+	"network.dns.disablePrefetch":               true,
+	"network.dns.disablePrefetchFromHTTPS":      true,
+	"network.predictor.enabled":                 false,
+	"network.predictor.enable-prefetch":         false,
+	"network.http.speculative-parallel-limit":   0,
+	"browser.urlbar.speculativeConnect.enabled": false,
+	"browser.places.speculativeConnect.enabled": false,
+	// Background pings and lookups
+	"browser.send_pings":                            false,
+	"beacon.enabled":                                false,
+	"geo.enabled":                                   false,
+	"network.trr.mode":                              5,
+	"network.captive-portal-service.enabled":        false,
+	"network.connectivity-service.enabled":          false,
+	"browser.safebrowsing.downloads.remote.enabled": false,
+}
+
+// errOverlayDoH is returned by the overlay network presets when Options.DoH
+// is set: they turn DNS-over-HTTPS off, so that every lookup goes through
+// the proxy.
+var errOverlayDoH = errors.New("DoH cannot be combined with an overlay network, which resolves names through its proxy")
+
+// overlayPrefs returns the preset preferences with the caller's own prefs on
+// top, so explicit Options.Prefs always win over a preset.
+func overlayPrefs(preset []map[string]interface{}, prefs map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for _, p := range preset {
+		mergePrefs(merged, p)
+	}
+	mergePrefs(merged, prefs)
+	return merged
+}
+
+// WaitForProxy waits up to timeout for the proxy at addr to accept a TCP
+// connection. With a zero timeout it checks exactly once.
+func WaitForProxy(addr string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s: %w", addr, ErrProxyUnreachable)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
@@ -0,0 +1,103 @@
+package fcw
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestI2PConfigApply(t *testing.T) {
+	opts := Options{Prefs: map[string]interface{}{"keyword.enabled": true}}
+	if err := (I2PConfig{}).Apply(&opts); err != nil {
+		t.Fatal(err)
+	}
+	prefs, err := opts.prefs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"network.proxy.type":                      1,
+		"network.proxy.http":                      "127.0.0.1",
+		"network.proxy.http_port":                 4444,
+		"network.proxy.ssl":                       "127.0.0.1",
+		"network.proxy.ssl_port":                  4444,
+		"network.proxy.no_proxies_on":             "localhost, 127.0.0.1",
+		"browser.fixup.domainsuffixwhitelist.i2p": true,
+		"media.peerconnection.enabled":            false,
+		"network.prefetch-next":                   false,
+		"network.dns.disablePrefetch":             true,
+		"network.predictor.enabled":               false,
+		"network.trr.mode":                        5,
+		// The caller's own preferences win over the preset.
+		"keyword.enabled": true,
+	}
+	for name, value := range want {
+		if prefs[name] != value {
+			t.Errorf("%s = %v, want %v", name, prefs[name], value)
+		}
+	}
+}
+
+func TestTorConfigApply(t *testing.T) {
+	opts := Options{Prefs: map[string]interface{}{"privacy.resistFingerprinting": false}}
+	if err := (TorConfig{SOCKS: "127.0.0.1:9150"}).Apply(&opts); err != nil {
+		t.Fatal(err)
+	}
+	prefs, err := opts.prefs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"network.proxy.type":             1,
+		"network.proxy.socks":            "127.0.0.1",
+		"network.proxy.socks_port":       9150,
+		"network.proxy.socks_version":    5,
+		"network.proxy.socks_remote_dns": true,
+		"network.dns.blockDotOnion":      false,
+		"media.peerconnection.enabled":   false,
+		// The caller's own preferences win over the preset.
+		"privacy.resistFingerprinting": false,
+	}
+	for name, value := range want {
+		if prefs[name] != value {
+			t.Errorf("%s = %v, want %v", name, prefs[name], value)
+		}
+	}
+}
+
+func TestWaitForProxy(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	if err := WaitForProxy(addr, 0); err != nil {
+		t.Errorf("WaitForProxy with a listener: %v", err)
+	}
+	l.Close()
+	if err := WaitForProxy(addr, 0); !errors.Is(err, ErrProxyUnreachable) {
+		t.Errorf("WaitForProxy on a closed port: %v", err)
+	}
+	if _, err := TorFirefox(t.TempDir(), TorConfig{SOCKS: addr}, Options{}); !errors.Is(err, ErrProxyUnreachable) {
+		t.Errorf("TorFirefox with tor not running: %v", err)
+	}
+	start := time.Now()
+	if err := WaitForProxy(addr, time.Second); !errors.Is(err, ErrProxyUnreachable) {
+		t.Errorf("WaitForProxy with a timeout on a closed port: %v", err)
+	}
+	if waited := time.Since(start); waited < time.Second {
+		t.Errorf("WaitForProxy gave up after %v", waited)
+	}
+}
+
+func TestOverlayDoH(t *testing.T) {
+	// The presets resolve names through their proxy, so DoH is rejected.
+	opts := Options{DoH: &DoHConfig{}}
+	if err := (I2PConfig{}).Apply(&opts); !errors.Is(err, errOverlayDoH) {
+		t.Errorf("I2PConfig.Apply with DoH set: %v", err)
+	}
+	if err := (TorConfig{}).Apply(&opts); !errors.Is(err, errOverlayDoH) {
+		t.Errorf("TorConfig.Apply with DoH set: %v", err)
+	}
+}
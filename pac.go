@@ -0,0 +1,209 @@
+package fcw
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// PACRoute is where a proxy auto-config file sends a request.
+type PACRoute int
+
+const (
+	// RouteDirect connects directly.
+	RouteDirect PACRoute = iota
+	// RouteI2P goes through the I2P router's HTTP proxy.
+	RouteI2P
+	// RouteTor goes through tor's SOCKS5 port.
+	RouteTor
+	// RouteBlock refuses the request.
+	RouteBlock
+)
+
+// PACConfig describes split routing between clearnet, I2P and Tor. ".i2p"
+// hosts always go to I2P and ".onion" hosts always go to Tor.
+type PACConfig struct {
+	// I2PProxy is the I2P router's HTTP proxy. It defaults to
+	// DefaultI2PHTTPProxy.
+	I2PProxy string
+	// TorSOCKS is tor's SOCKS5 port. It defaults to DefaultTorSOCKS.
+	TorSOCKS string
+	// Direct lists hosts which are connected to directly. An entry also
+	// matches every subdomain of the host. An entry with a port, like
+	// "localhost:8080", only matches that port.
+	Direct []string
+	// Default is the route for every other host.
+	Default PACRoute
+	// NoOverlays treats ".i2p" and ".onion" hosts like any other host.
+	NoOverlays bool
+	// OnBlocked is called with the target of every request refused by
+	// RouteBlock.
+	OnBlocked func(target string)
+}
+
+func (c *PACConfig) i2pProxy() string {
+	if c.I2PProxy == "" {
+		return DefaultI2PHTTPProxy
+	}
+	return c.I2PProxy
+}
+
+func (c *PACConfig) torSOCKS() string {
+	if c.TorSOCKS == "" {
+		return DefaultTorSOCKS
+	}
+	return c.TorSOCKS
+}
+
+// Validate reports whether the configuration is complete and consistent.
+func (c *PACConfig) Validate() error {
+	if c.Default < RouteDirect || c.Default > RouteBlock {
+		return fmt.Errorf("unknown PAC route %d", c.Default)
+	}
+	for _, addr := range []string{c.i2pProxy(), c.torSOCKS()} {
+		if _, _, err := splitProxyAddr(addr); err != nil {
+			return err
+		}
+	}
+	for _, entry := range c.Direct {
+		if _, _, err := splitDirectHost(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitDirectHost splits an entry of PACConfig.Direct into its host and
+// optional port.
+func splitDirectHost(entry string) (string, string, error) {
+	host, port := entry, ""
+	if h, p, err := net.SplitHostPort(entry); err == nil {
+		host, port = h, p
+		if n, err := strconv.Atoi(p); err != nil || n < 1 || n > 65535 {
+			return "", "", fmt.Errorf("invalid direct host %q", entry)
+		}
+	}
+	if host == "" || strings.ContainsAny(host, "\"\\ /") || (strings.Contains(host, ":") && net.ParseIP(host) == nil) {
+		return "", "", fmt.Errorf("invalid direct host %q", entry)
+	}
+	return strings.ToLower(host), port, nil
+}
+
+// route returns the PAC result for r. blockProxy is the proxy which refuses
+// blocked requests.
+func (c *PACConfig) route(r PACRoute, blockProxy string) string {
+	switch r {
+	case RouteI2P:
+		return "PROXY " + c.i2pProxy()
+	case RouteTor:
+		return "SOCKS5 " + c.torSOCKS()
+	case RouteBlock:
+		return "PROXY " + blockProxy
+	}
+	return "DIRECT"
+}
+
+// Script returns the proxy auto-config file for the configuration. Blocked
+// requests are sent to blockProxy, which should refuse them.
+func (c *PACConfig) Script(blockProxy string) (string, error) {
+	if err := c.Validate(); err != nil {
+		return "", err
+	}
+	direct := make([]string, len(c.Direct))
+	for i, entry := range c.Direct {
+		host, port, _ := splitDirectHost(entry)
+		direct[i] = fmt.Sprintf(`{host: "%s", port: "%s"}`, host, port)
+	}
+	i2p, tor := c.route(RouteI2P, blockProxy), c.route(RouteTor, blockProxy)
+	if c.NoOverlays {
+		i2p, tor = c.route(c.Default, blockProxy), c.route(c.Default, blockProxy)
+	}
+	return fmt.Sprintf(`function FindProxyForURL(url, host) {
+  host = host.toLowerCase();
+  var m = url.match(/^[a-z][a-z0-9+.-]*:\/\/(?:[^@\/]*@)?(?:\[[^\]]*\]|[^:\/?#]*)(?::(\d+))?/i);
+  var port = m && m[1] ? m[1] : (/^(https|wss):/i.test(url) ? "443" : "80");
+  var direct = [%s];
+  for (var i = 0; i < direct.length; i++) {
+    var d = direct[i];
+    if ((host == d.host || dnsDomainIs(host, "." + d.host)) && (d.port == "" || d.port == port)) {
+      return "DIRECT";
+    }
+  }
+  if (dnsDomainIs(host, ".i2p")) {
+    return "%s";
+  }
+  if (dnsDomainIs(host, ".onion")) {
+    return "%s";
+  }
+  return "%s";
+}
+`, strings.Join(direct, ", "), i2p, tor, c.route(c.Default, blockProxy)), nil
+}
+
+// PACServer serves a proxy auto-config file on the loopback interface. It
+// doubles as the proxy for blocked requests, which it refuses.
+type PACServer struct {
+	*loopbackServer
+	script    string
+	onBlocked func(target string)
+}
+
+// StartPACServer starts serving the proxy auto-config file for cfg on a random
+// loopback port.
+func StartPACServer(cfg PACConfig) (*PACServer, error) {
+	ls, err := listenLoopback()
+	if err != nil {
+		return nil, err
+	}
+	s := &PACServer{loopbackServer: ls, onBlocked: cfg.OnBlocked}
+	s.script, err = cfg.Script(ls.addr())
+	if err != nil {
+		ls.close()
+		return nil, err
+	}
+	ls.serve(s)
+	return s, nil
+}
+
+// URL returns the location of the proxy auto-config file.
+func (s *PACServer) URL() string {
+	return s.url("/proxy.pac")
+}
+
+// Close stops the server.
+func (s *PACServer) Close() error {
+	return s.close()
+}
+
+// ProxyConfig returns the configuration which makes Firefox use the server.
+func (s *PACServer) ProxyConfig() *ProxyConfig {
+	return &ProxyConfig{
+		Mode:      ProxyPAC,
+		PACURL:    s.URL(),
+		RemoteDNS: true,
+	}
+}
+
+func (s *PACServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect || r.URL.IsAbs() {
+		target := r.Host
+		if r.Method != http.MethodConnect {
+			target = r.URL.String()
+		}
+		log.Println("Blocked", target)
+		if s.onBlocked != nil {
+			s.onBlocked(target)
+		}
+		http.Error(w, "blocked", http.StatusForbidden)
+		return
+	}
+	if r.URL.Path != s.token+"/proxy.pac" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ns-proxy-autoconfig")
+	w.Write([]byte(s.script))
+}
@@ -0,0 +1,43 @@
+package fcw
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPACServer(t *testing.T) {
+	s, err := StartPACServer(PACConfig{Direct: []string{"Example.com"}, Default: RouteBlock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	resp, err := http.Get(s.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	for _, want := range []string{
+		`var direct = [{host: "example.com", port: ""}];`,
+		`return "PROXY 127.0.0.1:4444";`,
+		`return "SOCKS5 127.0.0.1:9050";`,
+		`return "PROXY ` + s.addr() + `";`,
+	} {
+		if !strings.Contains(string(script), want) {
+			t.Errorf("PAC script does not contain %s:\n%s", want, script)
+		}
+	}
+	proxyURL, _ := url.Parse("http://" + s.addr())
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	resp, err = client.Get("http://blocked.example.net/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("blocked request got status %d", resp.StatusCode)
+	}
+}
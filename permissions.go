@@ -0,0 +1,137 @@
+package fcw
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PermissionType is a site permission, as it is named in permissions.sqlite.
+type PermissionType string
+
+// The permissions sites most often ask for.
+const (
+	PermissionCamera            PermissionType = "camera"
+	PermissionMicrophone        PermissionType = "microphone"
+	PermissionScreen            PermissionType = "screen"
+	PermissionNotifications     PermissionType = "desktop-notification"
+	PermissionGeolocation       PermissionType = "geo"
+	PermissionPopups            PermissionType = "popup"
+	PermissionAutoplay          PermissionType = "autoplay-media"
+	PermissionPersistentStorage PermissionType = "persistent-storage"
+)
+
+// PermissionAction is whether a site gets a permission.
+type PermissionAction int
+
+// The values are those of nsIPermissionManager.
+const (
+	PermissionAllow  PermissionAction = 1
+	PermissionDeny   PermissionAction = 2
+	PermissionPrompt PermissionAction = 3
+)
+
+// permissionsSchema creates permissions.sqlite at the version Firefox
+// migrates from. moz_hosts is kept only so older versions can read the file.
+var permissionsSchema = []string{
+	"CREATE TABLE moz_perms (id INTEGER PRIMARY KEY, origin TEXT, type TEXT, permission INTEGER, expireType INTEGER, expireTime INTEGER, modificationTime INTEGER)",
+	"CREATE TABLE moz_hosts (id INTEGER PRIMARY KEY, host TEXT, type TEXT, permission INTEGER, expireType INTEGER, expireTime INTEGER, modificationTime INTEGER, isInBrowserElement INTEGER)",
+	"PRAGMA user_version = 12",
+}
+
+// moz_perms expireType values.
+const (
+	permissionExpireNever = 0
+	permissionExpireTime  = 2
+)
+
+// permissionOrigin returns origin in the form permissions.sqlite keys
+// permissions by: scheme, host and any port which is not the default.
+func permissionOrigin(origin string) (string, error) {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid origin %q", origin)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	port := u.Port()
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		port = ""
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	return scheme + "://" + host, nil
+}
+
+// SetPermission grants, denies or makes Firefox ask for the permission typ on
+// origin, like "https://meet.example.com", replacing any earlier decision. A
+// zero expiry never expires. It writes permissions.sqlite, creating it if
+// Firefox has not yet, so Firefox must not be running.
+func (p *Profile) SetPermission(origin string, typ PermissionType, action PermissionAction, expiry time.Time) error {
+	origin, err := permissionOrigin(origin)
+	if err != nil {
+		return err
+	}
+	switch action {
+	case PermissionAllow, PermissionDeny, PermissionPrompt:
+	default:
+		return fmt.Errorf("unknown permission action %d", action)
+	}
+	if typ == "" {
+		return fmt.Errorf("empty permission type")
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if err := p.checkUnlocked(); err != nil {
+		return err
+	}
+	path := filepath.Join(p.dir, "permissions.sqlite")
+	_, statErr := os.Stat(path)
+	if statErr != nil && !os.IsNotExist(statErr) {
+		return statErr
+	}
+	db, err := openSQLiteFile(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	fail := func(err error) error {
+		tx.Rollback()
+		return fmt.Errorf("permissions.sqlite: %w", err)
+	}
+	if os.IsNotExist(statErr) {
+		for _, stmt := range permissionsSchema {
+			if _, err := tx.Exec(stmt); err != nil {
+				return fail(err)
+			}
+		}
+	}
+	expireType, expireTime := permissionExpireNever, int64(0)
+	if !expiry.IsZero() {
+		expireType, expireTime = permissionExpireTime, expiry.UnixNano()/int64(time.Millisecond)
+	}
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	if _, err := tx.Exec("DELETE FROM moz_perms WHERE origin = ? AND type = ?", origin, string(typ)); err != nil {
+		return fail(err)
+	}
+	if _, err := tx.Exec("INSERT INTO moz_perms (origin, type, permission, expireType, expireTime, modificationTime) VALUES (?, ?, ?, ?, ?, ?)",
+		origin, string(typ), int(action), expireType, expireTime, now); err != nil {
+		return fail(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("permissions.sqlite: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package fcw
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPermissionOrigin(t *testing.T) {
+	for in, want := range map[string]string{
+		"https://Meet.Example.com/room/1": "https://meet.example.com",
+		"https://meet.example.com:443":    "https://meet.example.com",
+		"http://localhost:80/":            "http://localhost",
+		"http://localhost:8080":           "http://localhost:8080",
+		"https://[::1]:8443/":             "https://[::1]:8443",
+	} {
+		got, err := permissionOrigin(in)
+		if err != nil {
+			t.Errorf("%s: %v", in, err)
+		} else if got != want {
+			t.Errorf("%s: got %q, want %q", in, got, want)
+		}
+	}
+	for _, in := range []string{"", "meet.example.com", "https://"} {
+		if _, err := permissionOrigin(in); err == nil {
+			t.Errorf("%q: no error", in)
+		}
+	}
+}
+
+func TestSetPermission(t *testing.T) {
+	dir := t.TempDir()
+	p := NewProfile(dir)
+	if err := p.SetPermission("https://meet.example.com/", PermissionCamera, PermissionAllow, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	expiry := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := p.SetPermission("https://meet.example.com", PermissionMicrophone, PermissionPrompt, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetPermission("https://meet.example.com:443", PermissionMicrophone, PermissionAllow, expiry); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetPermission("https://meet.example.com", PermissionNotifications, 7, time.Time{}); err == nil {
+		t.Error("no error for an unknown action")
+	}
+
+	db, err := sql.Open("sqlite", filepath.Join(dir, "permissions.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != 12 {
+		t.Errorf("user_version %d, want 12", version)
+	}
+	rows, err := db.Query("SELECT origin, type, permission, expireType, expireTime FROM moz_perms ORDER BY type")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	type perm struct {
+		origin, typ                        string
+		permission, expireType, expireTime int64
+	}
+	var got []perm
+	for rows.Next() {
+		var r perm
+		if err := rows.Scan(&r.origin, &r.typ, &r.permission, &r.expireType, &r.expireTime); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []perm{
+		{"https://meet.example.com", "camera", 1, 0, 0},
+		{"https://meet.example.com", "microphone", 1, 2, expiry.UnixNano() / int64(time.Millisecond)},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("row %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package fcw
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Policies is an enterprise policies.json for a Firefox installation. Unlike
+// preferences in user.js, policies apply to every profile and cannot be
+// changed by the user. Only the policies used by this package are typed;
+// Firefox documents the rest at https://mozilla.github.io/policy-templates/.
+type Policies struct {
+	DisableAppUpdate           bool  `json:"DisableAppUpdate,omitempty"`
+	AppAutoUpdate              *bool `json:"AppAutoUpdate,omitempty"`
+	BackgroundAppUpdate        *bool `json:"BackgroundAppUpdate,omitempty"`
+	ExtensionUpdate            *bool `json:"ExtensionUpdate,omitempty"`
+	DisableDefaultBrowserAgent bool  `json:"DisableDefaultBrowserAgent,omitempty"`
+	DisableSystemAddonUpdate   bool  `json:"DisableSystemAddonUpdate,omitempty"`
+	DisableTelemetry           bool  `json:"DisableTelemetry,omitempty"`
+	DisableFirefoxStudies      bool  `json:"DisableFirefoxStudies,omitempty"`
+	DisableFirefoxAccounts     bool  `json:"DisableFirefoxAccounts,omitempty"`
+	DisablePocket              bool  `json:"DisablePocket,omitempty"`
+	DisableFormHistory         bool  `json:"DisableFormHistory,omitempty"`
+	DisableDeveloperTools      bool  `json:"DisableDeveloperTools,omitempty"`
+	DisablePrivateBrowsing     bool  `json:"DisablePrivateBrowsing,omitempty"`
+	DisableProfileImport       bool  `json:"DisableProfileImport,omitempty"`
+	DisableFeedbackCommands    bool  `json:"DisableFeedbackCommands,omitempty"`
+	DontCheckDefaultBrowser    bool  `json:"DontCheckDefaultBrowser,omitempty"`
+
+	ExtensionSettings map[string]ExtensionPolicy  `json:"ExtensionSettings,omitempty"`
+	Certificates      *CertificatesPolicy         `json:"Certificates,omitempty"`
+	Proxy             *ProxyPolicy                `json:"Proxy,omitempty"`
+	Homepage          *HomepagePolicy             `json:"Homepage,omitempty"`
+	Bookmarks         []BookmarkPolicy            `json:"Bookmarks,omitempty"`
+	Preferences       map[string]PreferencePolicy `json:"Preferences,omitempty"`
+	WebsiteFilter     *WebsiteFilterPolicy        `json:"WebsiteFilter,omitempty"`
+}
+
+// ExtensionPolicy controls one extension, or every extension under the ID "*".
+type ExtensionPolicy struct {
+	// InstallationMode is one of "allowed", "blocked", "force_installed" and
+	// "normal_installed".
+	InstallationMode string `json:"installation_mode"`
+	// InstallURL is where the extension is installed from, for the
+	// force_installed and normal_installed modes. It can be a file:// URL.
+	InstallURL string `json:"install_url,omitempty"`
+}
+
+// CertificatesPolicy adds certificate authorities.
+type CertificatesPolicy struct {
+	// ImportEnterpriseRoots trusts the operating system's certificates.
+	ImportEnterpriseRoots bool `json:"ImportEnterpriseRoots,omitempty"`
+	// Install lists certificate files to trust.
+	Install []string `json:"Install,omitempty"`
+}
+
+// ProxyPolicy configures and optionally locks the proxy settings.
+type ProxyPolicy struct {
+	// Mode is one of "none", "system", "manual", "autoDetect" and
+	// "autoConfig".
+	Mode                        string `json:"Mode"`
+	Locked                      bool   `json:"Locked,omitempty"`
+	HTTPProxy                   string `json:"HTTPProxy,omitempty"`
+	UseHTTPProxyForAllProtocols bool   `json:"UseHTTPProxyForAllProtocols,omitempty"`
+	SSLProxy                    string `json:"SSLProxy,omitempty"`
+	SOCKSProxy                  string `json:"SOCKSProxy,omitempty"`
+	SOCKSVersion                int    `json:"SOCKSVersion,omitempty"`
+	AutoConfigURL               string `json:"AutoConfigURL,omitempty"`
+	Passthrough                 string `json:"Passthrough,omitempty"`
+	UseProxyForDNS              bool   `json:"UseProxyForDNS,omitempty"`
+}
+
+// HomepagePolicy sets the home page and what is shown on startup.
+type HomepagePolicy struct {
+	URL        string   `json:"URL,omitempty"`
+	Locked     bool     `json:"Locked,omitempty"`
+	Additional []string `json:"Additional,omitempty"`
+	// StartPage is one of "none", "homepage", "previous-session" and
+	// "homepage-locked".
+	StartPage string `json:"StartPage,omitempty"`
+}
+
+// BookmarkPolicy adds a bookmark.
+type BookmarkPolicy struct {
+	Title   string `json:"Title"`
+	URL     string `json:"URL"`
+	Favicon string `json:"Favicon,omitempty"`
+	// Placement is "toolbar" or "menu".
+	Placement string `json:"Placement,omitempty"`
+	Folder    string `json:"Folder,omitempty"`
+}
+
+// PreferencePolicy sets a preference.
+type PreferencePolicy struct {
+	// Value must be a bool, a string or an integer.
+	Value interface{} `json:"Value"`
+	// Status is one of "default", "locked", "user" and "clear".
+	Status string `json:"Status,omitempty"`
+}
+
+// WebsiteFilterPolicy blocks pages matching the Block match patterns, like
+// "https://*/*", unless they also match one of the Exceptions.
+type WebsiteFilterPolicy struct {
+	Block      []string `json:"Block,omitempty"`
+	Exceptions []string `json:"Exceptions,omitempty"`
+}
+
+// AddExtension force-installs the extension id from installURL.
+func (p *Policies) AddExtension(id, installURL string) *Policies {
+	if p.ExtensionSettings == nil {
+		p.ExtensionSettings = map[string]ExtensionPolicy{}
+	}
+	p.ExtensionSettings[id] = ExtensionPolicy{InstallationMode: "force_installed", InstallURL: installURL}
+	return p
+}
+
+// AddCertificate trusts the certificate file at path.
+func (p *Policies) AddCertificate(path string) *Policies {
+	if p.Certificates == nil {
+		p.Certificates = &CertificatesPolicy{}
+	}
+	p.Certificates.Install = append(p.Certificates.Install, path)
+	return p
+}
+
+// AddBookmark adds a bookmark to the toolbar, optionally in folder.
+func (p *Policies) AddBookmark(title, url, folder string) *Policies {
+	p.Bookmarks = append(p.Bookmarks, BookmarkPolicy{Title: title, URL: url, Placement: "toolbar", Folder: folder})
+	return p
+}
+
+// LockPref sets the preference name to value and locks it.
+func (p *Policies) LockPref(name string, value interface{}) *Policies {
+	if p.Preferences == nil {
+		p.Preferences = map[string]PreferencePolicy{}
+	}
+	p.Preferences[name] = PreferencePolicy{Value: value, Status: "locked"}
+	return p
+}
+
+// SetProxy applies c as the proxy policy, locked so the user cannot change it.
+func (p *Policies) SetProxy(c *ProxyConfig) error {
+	policy, err := c.Policy()
+	if err != nil {
+		return err
+	}
+	policy.Locked = true
+	p.Proxy = policy
+	return nil
+}
+
+// Policy returns the proxy policy equivalent to the configuration.
+func (c *ProxyConfig) Policy() (*ProxyPolicy, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	policy := &ProxyPolicy{}
+	switch c.Mode {
+	case ProxyNone:
+		policy.Mode = "none"
+	case ProxySystem:
+		policy.Mode = "system"
+	case ProxyPAC:
+		policy.Mode = "autoConfig"
+		policy.AutoConfigURL = c.PACURL
+		policy.UseProxyForDNS = c.RemoteDNS
+	case ProxyManual:
+		policy.Mode = "manual"
+		policy.HTTPProxy = c.HTTP
+		policy.SSLProxy = c.HTTPS
+		if policy.SSLProxy == "" {
+			policy.SSLProxy = c.HTTP
+		}
+		policy.SOCKSProxy = c.SOCKS
+		if c.SOCKS != "" {
+			policy.SOCKSVersion = 5
+			if c.SOCKSVersion != 0 {
+				policy.SOCKSVersion = c.SOCKSVersion
+			}
+		}
+		policy.UseProxyForDNS = c.RemoteDNS
+	}
+	policy.Passthrough = strings.Join(c.NoProxy, ", ")
+	return policy, nil
+}
+
+func oneOf(what, value string, allowed ...string) error {
+	for _, a := range allowed {
+		if value == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid %s %q, must be one of %v", what, value, allowed)
+}
+
+func validPolicyURL(what, value string) error {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" {
+		return fmt.Errorf("invalid %s URL %q", what, value)
+	}
+	return nil
+}
+
+// Validate checks the policies against the schema Firefox enforces, so that
+// mistakes show up here rather than as a policy Firefox silently ignores.
+func (p *Policies) Validate() error {
+	for id, ext := range p.ExtensionSettings {
+		if id == "" {
+			return fmt.Errorf("extension policy without an ID")
+		}
+		if err := oneOf("installation_mode for "+id, ext.InstallationMode, "allowed", "blocked", "force_installed", "normal_installed"); err != nil {
+			return err
+		}
+		if ext.InstallationMode == "force_installed" || ext.InstallationMode == "normal_installed" {
+			if err := validPolicyURL("install", ext.InstallURL); err != nil {
+				return err
+			}
+		}
+	}
+	if p.Certificates != nil {
+		for _, path := range p.Certificates.Install {
+			if path == "" {
+				return fmt.Errorf("empty certificate path")
+			}
+		}
+	}
+	if p.Proxy != nil {
+		if err := oneOf("proxy mode", p.Proxy.Mode, "none", "system", "manual", "autoDetect", "autoConfig"); err != nil {
+			return err
+		}
+		if p.Proxy.SOCKSVersion != 0 && p.Proxy.SOCKSVersion != 4 && p.Proxy.SOCKSVersion != 5 {
+			return fmt.Errorf("invalid SOCKS version %d", p.Proxy.SOCKSVersion)
+		}
+		if p.Proxy.Mode == "autoConfig" {
+			if err := validPolicyURL("proxy auto-config", p.Proxy.AutoConfigURL); err != nil {
+				return err
+			}
+		}
+	}
+	if p.Homepage != nil {
+		for _, u := range append([]string{p.Homepage.URL}, p.Homepage.Additional...) {
+			if u == "" {
+				continue
+			}
+			if err := validPolicyURL("homepage", u); err != nil {
+				return err
+			}
+		}
+		if p.Homepage.StartPage != "" {
+			if err := oneOf("start page", p.Homepage.StartPage, "none", "homepage", "previous-session", "homepage-locked"); err != nil {
+				return err
+			}
+		}
+	}
+	for _, b := range p.Bookmarks {
+		if b.Title == "" {
+			return fmt.Errorf("bookmark for %q has no title", b.URL)
+		}
+		if err := validPolicyURL("bookmark", b.URL); err != nil {
+			return err
+		}
+		if b.Placement != "" {
+			if err := oneOf("bookmark placement", b.Placement, "toolbar", "menu"); err != nil {
+				return err
+			}
+		}
+	}
+	if p.WebsiteFilter != nil {
+		for _, pattern := range append(append([]string{}, p.WebsiteFilter.Block...), p.WebsiteFilter.Exceptions...) {
+			if !strings.Contains(pattern, "://") && pattern != "<all_urls>" {
+				return fmt.Errorf("invalid website filter pattern %q", pattern)
+			}
+		}
+	}
+	for name, pref := range p.Preferences {
+		if _, err := formatPrefValue(pref.Value); err != nil {
+			return fmt.Errorf("preference policy %q: %w", name, err)
+		}
+		if pref.Status != "" {
+			if err := oneOf("status for preference "+name, pref.Status, "default", "locked", "user", "clear"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PoliciesPath returns the location of policies.json in a Firefox installation
+// directory, see InstallDir.
+func PoliciesPath(installDir string) string {
+	return filepath.Join(installDir, "distribution", "policies.json")
+}
+
+func (p *Policies) toMap() (map[string]interface{}, error) {
+	content, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(content, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Write validates the policies and replaces the installation's policies.json
+// with them.
+func (p *Policies) Write(installDir string) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	policies, err := p.toMap()
+	if err != nil {
+		return err
+	}
+	return writePoliciesFile(PoliciesPath(installDir), map[string]interface{}{"policies": policies})
+}
+
+// Merge validates the policies and adds them to the installation's
+// policies.json, keeping the policies it already sets which p does not.
+func (p *Policies) Merge(installDir string) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	policies, err := p.toMap()
+	if err != nil {
+		return err
+	}
+	path := PoliciesPath(installDir)
+	doc, err := readPoliciesFile(path)
+	if err != nil {
+		return err
+	}
+	existing, _ := doc["policies"].(map[string]interface{})
+	if existing == nil {
+		existing = map[string]interface{}{}
+	}
+	for name, value := range policies {
+		existing[name] = value
+	}
+	doc["policies"] = existing
+	return writePoliciesFile(path, doc)
+}
+
+func writePoliciesFile(path string, doc map[string]interface{}) error {
+	content, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0o644)
+}
+
+// readPoliciesFile reads the policies.json at path, or returns an empty
+// document if there is none.
+func readPoliciesFile(path string) (map[string]interface{}, error) {
+	doc := map[string]interface{}{}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return doc, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return doc, nil
+}
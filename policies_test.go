@@ -0,0 +1,57 @@
+package fcw
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestPoliciesMerge(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(dir+"/distribution", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(PoliciesPath(dir), []byte(`{"policies": {"DisablePocket": true}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := &Policies{DisableTelemetry: true}
+	p.AddBookmark("Wiki", "https://wiki.example.com", "Tools").LockPref("browser.startup.page", 3)
+	if err := p.SetProxy(&ProxyConfig{Mode: ProxyManual, HTTP: "127.0.0.1:4444"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Merge(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := DisableUpdates(dir + "/firefox-does-not-exist"); err == nil {
+		t.Error("DisableUpdates succeeded without an executable")
+	}
+	content, err := os.ReadFile(PoliciesPath(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Policies map[string]json.RawMessage `json:"policies"`
+	}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"DisablePocket", "DisableTelemetry", "Bookmarks", "Preferences", "Proxy"} {
+		if _, ok := doc.Policies[name]; !ok {
+			t.Errorf("policies.json lacks %s:\n%s", name, content)
+		}
+	}
+
+	invalid := []*Policies{
+		{Proxy: &ProxyPolicy{Mode: "manually"}},
+		{Bookmarks: []BookmarkPolicy{{Title: "x", URL: "not a url"}}},
+		{Homepage: &HomepagePolicy{StartPage: "blank"}},
+		{Preferences: map[string]PreferencePolicy{"a.b": {Value: 1.5}}},
+		{ExtensionSettings: map[string]ExtensionPolicy{"x@y": {InstallationMode: "force_installed"}}},
+		{WebsiteFilter: &WebsiteFilterPolicy{Block: []string{"example.com"}}},
+	}
+	for _, p := range invalid {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", p)
+		}
+	}
+}
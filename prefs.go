@@ -0,0 +1,268 @@
+package fcw
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Profile is a Firefox profile directory on disk. Preferences set on a Profile
+// are written to its user.js, which Firefox applies every time it starts.
+type Profile struct {
+	dir string
+	// manifest is set while changes are being recorded, see record.
+	manifest *Manifest
+}
+
+// NewProfile returns a Profile for the directory at dir.
+func NewProfile(dir string) *Profile {
+	return &Profile{dir: dir}
+}
+
+func (p *Profile) userJS() string {
+	return filepath.Join(p.dir, "user.js")
+}
+
+// SetPref sets the preference name to value in the profile's user.js, replacing
+// any existing value. value must be a bool, a string or an integer.
+func (p *Profile) SetPref(name string, value interface{}) error {
+	return p.SetPrefs(map[string]interface{}{name: value})
+}
+
+// SetPrefs sets every preference in prefs in the profile's user.js. Setting
+// the same preferences twice leaves user.js unchanged.
+func (p *Profile) SetPrefs(prefs map[string]interface{}) error {
+	return p.setPrefsIn("user.js", prefs)
+}
+
+// GetPref returns the value of the preference name from the profile's user.js,
+// and whether it was set there at all.
+func (p *Profile) GetPref(name string) (interface{}, bool, error) {
+	pf, err := loadPrefFile(p.userJS())
+	if err != nil {
+		return nil, false, err
+	}
+	value, ok := pf.get(name)
+	return value, ok, nil
+}
+
+// DeletePref removes the preference name from the profile's user.js. It is not
+// an error if the preference was not set.
+func (p *Profile) DeletePref(name string) error {
+	pf, err := loadPrefFile(p.userJS())
+	if err != nil {
+		return err
+	}
+	pf.remove(name)
+	return pf.save()
+}
+
+func sortedPrefNames(prefs map[string]interface{}) []string {
+	names := make([]string, 0, len(prefs))
+	for name := range prefs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// prefLine matches a single preference statement as found in user.js, prefs.js
+// and autoconfig files.
+var prefLine = regexp.MustCompile(`^\s*(user_pref|pref|lockPref|defaultPref|sticky_pref)\(\s*"((?:[^"\\]|\\.)*)"\s*,\s*(.+?)\s*\)\s*;`)
+
+// prefFile is a preference file held in memory line by line, so that
+// statements and comments which are not touched survive a rewrite.
+type prefFile struct {
+	path  string
+	lines []string
+	dirty bool
+}
+
+func loadPrefFile(path string) (*prefFile, error) {
+	pf := &prefFile{path: path}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return pf, nil
+		}
+		return nil, err
+	}
+	text := strings.TrimRight(string(content), "\n")
+	if text != "" {
+		pf.lines = strings.Split(text, "\n")
+	}
+	return pf, nil
+}
+
+func (pf *prefFile) save() error {
+	if !pf.dirty {
+		return nil
+	}
+	out := strings.Join(pf.lines, "\n")
+	if out != "" {
+		out += "\n"
+	}
+	if err := os.WriteFile(pf.path, []byte(out), 0o644); err != nil {
+		return err
+	}
+	pf.dirty = false
+	return nil
+}
+
+// find returns the index of the last statement setting name, which is the one
+// Firefox honours, or -1.
+func (pf *prefFile) find(name string) int {
+	found := -1
+	for i, line := range pf.lines {
+		if n, _, ok := parsePrefLine(line); ok && n == name {
+			found = i
+		}
+	}
+	return found
+}
+
+func (pf *prefFile) get(name string) (interface{}, bool) {
+	i := pf.find(name)
+	if i < 0 {
+		return nil, false
+	}
+	_, raw, _ := parsePrefLine(pf.lines[i])
+	value, err := parsePrefValue(raw)
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+func (pf *prefFile) set(name string, value interface{}) error {
+	line, err := formatPref("user_pref", name, value)
+	if err != nil {
+		return err
+	}
+	if i := pf.find(name); i >= 0 {
+		if pf.lines[i] == line {
+			return nil
+		}
+		pf.lines[i] = line
+	} else {
+		pf.lines = append(pf.lines, line)
+	}
+	pf.dirty = true
+	return nil
+}
+
+func (pf *prefFile) remove(name string) {
+	kept := pf.lines[:0]
+	for _, line := range pf.lines {
+		if n, _, ok := parsePrefLine(line); ok && n == name {
+			pf.dirty = true
+			continue
+		}
+		kept = append(kept, line)
+	}
+	pf.lines = kept
+}
+
+// revert puts back line as the statement setting name, or removes name if line
+// is empty.
+func (pf *prefFile) revert(name, line string) {
+	if line == "" {
+		pf.remove(name)
+		return
+	}
+	if i := pf.find(name); i >= 0 {
+		if pf.lines[i] != line {
+			pf.lines[i] = line
+			pf.dirty = true
+		}
+		return
+	}
+	pf.lines = append(pf.lines, line)
+	pf.dirty = true
+}
+
+func (pf *prefFile) hasPrefs() bool {
+	for _, line := range pf.lines {
+		if _, _, ok := parsePrefLine(line); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func parsePrefLine(line string) (name, raw string, ok bool) {
+	m := prefLine.FindStringSubmatch(line)
+	if m == nil {
+		return "", "", false
+	}
+	name, err := strconv.Unquote(`"` + m[2] + `"`)
+	if err != nil {
+		return "", "", false
+	}
+	return name, m[3], true
+}
+
+func parsePrefValue(raw string) (interface{}, error) {
+	switch raw {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if strings.HasPrefix(raw, `"`) {
+		return strconv.Unquote(raw)
+	}
+	if strings.HasPrefix(raw, `'`) && strings.HasSuffix(raw, `'`) && len(raw) > 1 {
+		return strings.Replace(raw[1:len(raw)-1], `\'`, `'`, -1), nil
+	}
+	i, err := strconv.ParseInt(raw, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid preference value %s", raw)
+	}
+	return int(i), nil
+}
+
+// formatPref renders a single preference statement using fn, which is one of
+// user_pref, pref or lockPref.
+func formatPref(fn, name string, value interface{}) (string, error) {
+	quotedName, err := quotePrefString(name)
+	if err != nil {
+		return "", err
+	}
+	v, err := formatPrefValue(value)
+	if err != nil {
+		return "", fmt.Errorf("preference %q: %w", name, err)
+	}
+	return fn + "(" + quotedName + ", " + v + ");", nil
+}
+
+func formatPrefValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case bool:
+		return strconv.FormatBool(v), nil
+	case string:
+		return quotePrefString(v)
+	case int:
+		return strconv.Itoa(v), nil
+	case int8, int16, int32, int64, uint8, uint16, uint32, uint64, uint:
+		return fmt.Sprintf("%d", v), nil
+	default:
+		return "", fmt.Errorf("unsupported preference type %T", value)
+	}
+}
+
+func quotePrefString(s string) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
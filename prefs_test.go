@@ -0,0 +1,46 @@
+package fcw
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfilePrefs(t *testing.T) {
+	dir := t.TempDir()
+	userJS := filepath.Join(dir, "user.js")
+	if err := os.WriteFile(userJS, []byte("// keep me\nuser_pref(\"browser.startup.page\", 1);\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := NewProfile(dir)
+	prefs := map[string]interface{}{
+		"browser.startup.page":     3,
+		"browser.startup.homepage": "https://example.com/?a=1&b=\"2\"",
+		"media.autoplay.default":   false,
+	}
+	for i := 0; i < 2; i++ {
+		if err := p.SetPrefs(prefs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, want := range prefs {
+		got, ok, err := p.GetPref(name)
+		if err != nil || !ok || got != want {
+			t.Errorf("GetPref(%q) = %v, %v, %v; want %v", name, got, ok, err, want)
+		}
+	}
+	if err := p.DeletePref("media.autoplay.default"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := p.GetPref("media.autoplay.default"); ok {
+		t.Error("media.autoplay.default still set after DeletePref")
+	}
+	content, err := os.ReadFile(userJS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "// keep me\nuser_pref(\"browser.startup.page\", 3);\nuser_pref(\"browser.startup.homepage\", \"https://example.com/?a=1&b=\\\"2\\\"\");\n"
+	if string(content) != want {
+		t.Errorf("user.js =\n%s\nwant\n%s", content, want)
+	}
+}
@@ -0,0 +1,141 @@
+package fcw
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+var (
+	// ErrNoProfile is returned when a profile directory does not exist.
+	ErrNoProfile = errors.New("profile directory does not exist")
+	// ErrNotDirectory is returned when a profile path, or its parent, is
+	// not a directory.
+	ErrNotDirectory = errors.New("not a directory")
+	// ErrMissingParent is returned when a profile directory cannot be
+	// created because its parent does not exist.
+	ErrMissingParent = errors.New("parent directory does not exist")
+	// ErrProfileNotEmpty is returned when a profile is cloned into a
+	// directory which already has files in it.
+	ErrProfileNotEmpty = errors.New("profile directory is not empty")
+	// ErrProfileLocked is returned when a running Firefox is using the
+	// profile.
+	ErrProfileLocked = errors.New("profile is in use by Firefox")
+	// ErrPermission is returned when a profile directory cannot be
+	// created, read or written.
+	ErrPermission = errors.New("permission denied")
+)
+
+// ProfileError describes why a profile directory cannot be used.
+type ProfileError struct {
+	// Path is the path the error is about, the profile directory or its
+	// parent.
+	Path string
+	// Err is one of ErrNoProfile, ErrNotDirectory, ErrMissingParent,
+	// ErrProfileNotEmpty, ErrProfileLocked and ErrPermission.
+	Err error
+	// Cause is the underlying error from the file system, if any.
+	Cause error
+}
+
+func (e *ProfileError) Error() string {
+	msg := "profile " + e.Path + ": " + e.Err.Error()
+	if e.Cause != nil {
+		msg += ": " + e.Cause.Error()
+	}
+	return msg
+}
+
+// Unwrap returns Err, so errors.Is matches the sentinel errors.
+func (e *ProfileError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the underlying file system error matches target, like
+// fs.ErrPermission.
+func (e *ProfileError) Is(target error) bool {
+	return e.Cause != nil && errors.Is(e.Cause, target)
+}
+
+// profileError turns a file system error about path into a ProfileError.
+func profileError(path string, err error) error {
+	switch {
+	case os.IsNotExist(err):
+		return &ProfileError{Path: path, Err: ErrNoProfile, Cause: err}
+	case os.IsPermission(err):
+		return &ProfileError{Path: path, Err: ErrPermission, Cause: err}
+	}
+	return err
+}
+
+// Path returns the profile's directory.
+func (p *Profile) Path() string {
+	return p.dir
+}
+
+// Validate checks that the profile directory exists, is a directory and can
+// be read and written.
+func (p *Profile) Validate() error {
+	info, err := os.Stat(p.dir)
+	if err != nil {
+		return profileError(p.dir, err)
+	}
+	if !info.IsDir() {
+		return &ProfileError{Path: p.dir, Err: ErrNotDirectory}
+	}
+	if _, err := os.ReadDir(p.dir); err != nil {
+		return profileError(p.dir, err)
+	}
+	f, err := os.CreateTemp(p.dir, ".fpw-check-")
+	if err != nil {
+		return profileError(p.dir, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// Open checks that the profile directory already exists and can be used, see
+// Validate.
+func (p *Profile) Open() error {
+	return p.Validate()
+}
+
+// Create creates the profile directory if it does not exist yet, and checks
+// that it can be used. Its parent directory must exist already.
+func (p *Profile) Create() error {
+	// Stat also fails, without IsNotExist, when the parent is a file.
+	if _, err := os.Stat(p.dir); err != nil && !os.IsPermission(err) {
+		parent := filepath.Dir(p.dir)
+		info, err := os.Stat(parent)
+		if os.IsNotExist(err) {
+			return &ProfileError{Path: parent, Err: ErrMissingParent, Cause: err}
+		} else if err != nil {
+			return profileError(parent, err)
+		}
+		if !info.IsDir() {
+			return &ProfileError{Path: parent, Err: ErrNotDirectory}
+		}
+		if err := os.Mkdir(p.dir, 0o755); err != nil && !os.IsExist(err) {
+			return profileError(p.dir, err)
+		}
+	}
+	return p.Validate()
+}
+
+// Locked reports whether a running Firefox is using the profile.
+func (p *Profile) Locked() (bool, error) {
+	return p.locked()
+}
+
+// checkUnlocked returns a ProfileError if a running Firefox is using the
+// profile.
+func (p *Profile) checkUnlocked() error {
+	locked, err := p.locked()
+	if err != nil {
+		return err
+	}
+	if locked {
+		return &ProfileError{Path: p.dir, Err: ErrProfileLocked}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package fcw
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfileCreateOpen(t *testing.T) {
+	dir := t.TempDir()
+	p := NewProfile(filepath.Join(dir, "app"))
+	if err := p.Open(); !errors.Is(err, ErrNoProfile) {
+		t.Errorf("Open of a missing profile = %v, want ErrNoProfile", err)
+	}
+	if err := p.Create(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Open(); err != nil {
+		t.Errorf("Open after Create = %v", err)
+	}
+	if err := p.Create(); err != nil {
+		t.Errorf("Create of an existing profile = %v", err)
+	}
+
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewProfile(file).Create(); !errors.Is(err, ErrNotDirectory) {
+		t.Errorf("Create over a file = %v, want ErrNotDirectory", err)
+	}
+	if err := NewProfile(filepath.Join(file, "app")).Create(); !errors.Is(err, ErrNotDirectory) {
+		t.Errorf("Create inside a file = %v, want ErrNotDirectory", err)
+	}
+	missing := NewProfile(filepath.Join(dir, "missing", "app"))
+	err := missing.Create()
+	var perr *ProfileError
+	if !errors.As(err, &perr) || perr.Err != ErrMissingParent || perr.Path != filepath.Join(dir, "missing") {
+		t.Errorf("Create with a missing parent = %v, want ErrMissingParent", err)
+	}
+	if _, err := os.Stat(missing.Path()); !os.IsNotExist(err) {
+		t.Errorf("Create with a missing parent created %s", missing.Path())
+	}
+}
@@ -0,0 +1,289 @@
+package fcw
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ErrProfileNotFound is returned when profiles.ini has no profile with the
+// requested name.
+var ErrProfileNotFound = errors.New("profile not found in profiles.ini")
+
+// FirefoxDataDir returns the directory holding profiles.ini. It defaults to
+// LocateFirefoxDataDir and can be replaced.
+var FirefoxDataDir = LocateFirefoxDataDir
+
+// LocateFirefoxDataDir returns the directory Firefox keeps profiles.ini and
+// installs.ini in for the current user, or an empty string if it cannot be
+// determined.
+func LocateFirefoxDataDir() string {
+	if runtime.GOOS == "windows" {
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "Mozilla", "Firefox")
+		}
+		return ""
+	}
+	userHome, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	if runtime.GOOS == "darwin" {
+		return filepath.Join(userHome, "Library", "Application Support", "Firefox")
+	}
+	return filepath.Join(userHome, ".mozilla", "firefox")
+}
+
+// SystemProfile is a profile listed in the user's profiles.ini, the ones
+// about:profiles and the profile manager show.
+type SystemProfile struct {
+	Name string
+	// Path is the absolute path to the profile directory.
+	Path string
+	// Relative is set when profiles.ini stores the path relative to the
+	// data directory.
+	Relative bool
+	// Default is set for the profile Firefox starts with, by itself or
+	// for any installation in installs.ini.
+	Default bool
+}
+
+// Profile returns the Profile for the profile's directory.
+func (s SystemProfile) Profile() *Profile {
+	return NewProfile(s.Path)
+}
+
+// SystemProfiles lists the profiles in profiles.ini in FirefoxDataDir.
+func SystemProfiles() ([]SystemProfile, error) {
+	dataDir := FirefoxDataDir()
+	if dataDir == "" {
+		return nil, fmt.Errorf("Firefox data directory not found")
+	}
+	profiles, err := readINI(filepath.Join(dataDir, "profiles.ini"))
+	if err != nil {
+		return nil, err
+	}
+	installs, err := readINI(filepath.Join(dataDir, "installs.ini"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	// Newer versions keep the default profile of each installation in
+	// installs.ini and copy it into [Install...] sections of profiles.ini.
+	defaults := map[string]bool{}
+	addDefault := func(section *iniSection) {
+		if path, ok := section.get("Default"); ok {
+			defaults[profilePath(dataDir, path, !filepath.IsAbs(path))] = true
+		}
+	}
+	for _, section := range profiles {
+		if strings.HasPrefix(section.name, "Install") {
+			addDefault(section)
+		}
+	}
+	for _, section := range installs {
+		addDefault(section)
+	}
+	var list []SystemProfile
+	for _, section := range profiles {
+		if !strings.HasPrefix(section.name, "Profile") {
+			continue
+		}
+		name, _ := section.get("Name")
+		path, _ := section.get("Path")
+		relative, _ := section.get("IsRelative")
+		def, _ := section.get("Default")
+		p := SystemProfile{
+			Name:     name,
+			Path:     profilePath(dataDir, path, relative == "1"),
+			Relative: relative == "1",
+		}
+		p.Default = def == "1" || defaults[p.Path]
+		list = append(list, p)
+	}
+	return list, nil
+}
+
+// profilePath resolves a path from profiles.ini or installs.ini, which uses
+// forward slashes for relative paths on every platform.
+func profilePath(dataDir, path string, relative bool) string {
+	if relative {
+		return filepath.Join(dataDir, filepath.FromSlash(path))
+	}
+	return filepath.Clean(path)
+}
+
+// FindSystemProfile returns the profile called name in profiles.ini.
+func FindSystemProfile(name string) (*SystemProfile, error) {
+	profiles, err := SystemProfiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range profiles {
+		if p.Name == name {
+			return &p, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+}
+
+// LaunchProfile launches Firefox like LaunchFirefox, with the profile called
+// name in profiles.ini.
+func LaunchProfile(name string, opts Options, args ...string) (UI, error) {
+	p, err := FindSystemProfile(name)
+	if err != nil {
+		return nil, err
+	}
+	return LaunchFirefox(p.Path, opts, args...)
+}
+
+// RegisterProfile adds the profile directory dir to profiles.ini as name, so
+// it shows up in about:profiles and the profile manager. dir is stored
+// relative to the data directory if it is inside it. Firefox rewrites
+// profiles.ini when it exits, so RegisterProfile fails while any profile
+// listed there is in use.
+func RegisterProfile(name, dir string) error {
+	dataDir := FirefoxDataDir()
+	if dataDir == "" {
+		return fmt.Errorf("Firefox data directory not found")
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if err := NewProfile(dir).Open(); err != nil {
+		return err
+	}
+	path := filepath.Join(dataDir, "profiles.ini")
+	ini, err := readINI(path)
+	if os.IsNotExist(err) {
+		if err := os.MkdirAll(dataDir, 0o700); err != nil {
+			return err
+		}
+		ini = iniFile{{name: "General", keys: []iniKey{
+			{"StartWithLastProfile", "1"},
+			{"Version", "2"},
+		}}}
+	} else if err != nil {
+		return err
+	}
+	n := 0
+	for _, section := range ini {
+		if !strings.HasPrefix(section.name, "Profile") {
+			continue
+		}
+		if existing, _ := section.get("Name"); existing == name {
+			return fmt.Errorf("profile %s is already registered", name)
+		}
+		if path, ok := section.get("Path"); ok {
+			relative, _ := section.get("IsRelative")
+			if err := NewProfile(profilePath(dataDir, path, relative == "1")).checkUnlocked(); err != nil {
+				return fmt.Errorf("Firefox is running and would overwrite profiles.ini: %w", err)
+			}
+		}
+		if i, err := strconv.Atoi(strings.TrimPrefix(section.name, "Profile")); err == nil && i >= n {
+			n = i + 1
+		}
+	}
+	relative := "0"
+	stored := dir
+	if rel, err := filepath.Rel(dataDir, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		relative = "1"
+		stored = filepath.ToSlash(rel)
+	}
+	ini = append(ini, &iniSection{name: "Profile" + strconv.Itoa(n), keys: []iniKey{
+		{"Name", name},
+		{"IsRelative", relative},
+		{"Path", stored},
+	}})
+	return ini.write(path)
+}
+
+type iniKey struct {
+	key, value string
+}
+
+type iniSection struct {
+	name string
+	keys []iniKey
+}
+
+func (s *iniSection) get(key string) (string, bool) {
+	for _, k := range s.keys {
+		if k.key == key {
+			return k.value, true
+		}
+	}
+	return "", false
+}
+
+// iniFile is an INI file in the simple format Firefox writes: sections of
+// key=value lines, kept in order so they are written back the same way.
+type iniFile []*iniSection
+
+func readINI(path string) (iniFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseINI(data)
+}
+
+func parseINI(data []byte) (iniFile, error) {
+	var ini iniFile
+	var section *iniSection
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = &iniSection{name: line[1 : len(line)-1]}
+			ini = append(ini, section)
+		default:
+			i := strings.Index(line, "=")
+			if i < 0 || section == nil {
+				return nil, fmt.Errorf("line %d: not a section or key=value: %q", n, line)
+			}
+			section.keys = append(section.keys, iniKey{
+				key:   strings.TrimSpace(line[:i]),
+				value: strings.TrimSpace(line[i+1:]),
+			})
+		}
+	}
+	return ini, scanner.Err()
+}
+
+func (ini iniFile) bytes() []byte {
+	var buf bytes.Buffer
+	for i, section := range ini {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "[%s]\n", section.name)
+		for _, k := range section.keys {
+			fmt.Fprintf(&buf, "%s=%s\n", k.key, k.value)
+		}
+	}
+	return buf.Bytes()
+}
+
+// write replaces the file at path through a temporary file, so Firefox never
+// sees half of it.
+func (ini iniFile) write(path string) error {
+	tmp := path + ".fpw-tmp"
+	if err := os.WriteFile(tmp, ini.bytes(), 0o644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
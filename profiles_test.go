@@ -0,0 +1,127 @@
+package fcw
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestSystemProfiles(t *testing.T) {
+	dataDir := t.TempDir()
+	defer func(locate func() string) { FirefoxDataDir = locate }(FirefoxDataDir)
+	FirefoxDataDir = func() string { return dataDir }
+	external := t.TempDir()
+
+	profilesINI := `[Install4F96D1932A9F858E]
+Default=Profiles/abcd.default-release
+Locked=1
+
+[Profile1]
+Name=default
+IsRelative=1
+Path=Profiles/efgh.default
+Default=1
+
+[Profile0]
+Name=default-release
+IsRelative=1
+Path=Profiles/abcd.default-release
+
+[General]
+StartWithLastProfile=1
+Version=2
+`
+	if err := os.WriteFile(filepath.Join(dataDir, "profiles.ini"), []byte(profilesINI), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := []SystemProfile{
+		{Name: "default", Path: filepath.Join(dataDir, "Profiles", "efgh.default"), Relative: true, Default: true},
+		{Name: "default-release", Path: filepath.Join(dataDir, "Profiles", "abcd.default-release"), Relative: true, Default: true},
+	}
+	got, err := SystemProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SystemProfiles() = %+v, want %+v", got, want)
+	}
+
+	if err := RegisterProfile("app", external); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterProfile("app", external); err == nil {
+		t.Error("registering a name twice succeeded")
+	}
+	p, err := FindSystemProfile("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (SystemProfile{Name: "app", Path: external}); *p != want {
+		t.Errorf("registered profile = %+v, want %+v", *p, want)
+	}
+	if _, err := FindSystemProfile("missing"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("FindSystemProfile(missing) = %v, want ErrProfileNotFound", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dataDir, "profiles.ini"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ini, err := parseINI(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	section := ini[len(ini)-1]
+	if section.name != "Profile2" {
+		t.Errorf("registered profile section = %s, want Profile2", section.name)
+	}
+	if locked, _ := ini[0].get("Locked"); locked != "1" {
+		t.Error("install section was not kept")
+	}
+}
+
+func TestRegisterProfileRefusesRunningFirefox(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a lock symlink")
+	}
+	dataDir := t.TempDir()
+	defer func(locate func() string) { FirefoxDataDir = locate }(FirefoxDataDir)
+	FirefoxDataDir = func() string { return dataDir }
+	running := filepath.Join(dataDir, "Profiles", "abcd.default")
+	if err := os.MkdirAll(running, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("127.0.0.1:+"+strconv.Itoa(os.Getpid()), filepath.Join(running, "lock")); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dataDir, "profiles.ini")
+	original := "[Profile0]\nName=default\nIsRelative=1\nPath=Profiles/abcd.default\n"
+	if err := os.WriteFile(path, []byte(original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterProfile("app", t.TempDir()); !errors.Is(err, ErrProfileLocked) {
+		t.Errorf("RegisterProfile = %v while Firefox runs, want ErrProfileLocked", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != original {
+		t.Errorf("profiles.ini changed:\n%s", content)
+	}
+
+	// A failed write leaves no temporary file behind.
+	if err := os.Mkdir(filepath.Join(dataDir, "blocked.ini"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "blocked.ini", "file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ini := iniFile{{name: "General", keys: []iniKey{{"Version", "2"}}}}
+	if err := ini.write(filepath.Join(dataDir, "blocked.ini")); err == nil {
+		t.Error("replacing a directory succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "blocked.ini.fpw-tmp")); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package fcw
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ProxyMode selects how Firefox finds its proxy.
+type ProxyMode int
+
+const (
+	// ProxyNone connects directly.
+	ProxyNone ProxyMode = iota
+	// ProxySystem uses the operating system's proxy settings.
+	ProxySystem
+	// ProxyManual uses the HTTP, HTTPS and SOCKS proxies in the ProxyConfig.
+	ProxyManual
+	// ProxyPAC loads a proxy auto-config file from PACURL.
+	ProxyPAC
+)
+
+// network.proxy.type values for each ProxyMode.
+var proxyTypes = map[ProxyMode]int{
+	ProxyNone:   0,
+	ProxyManual: 1,
+	ProxyPAC:    2,
+	ProxySystem: 5,
+}
+
+// ProxyConfig describes the proxy a profile sends its traffic through.
+// Addresses are "host:port" pairs.
+type ProxyConfig struct {
+	Mode ProxyMode
+	// HTTP is the proxy for plain HTTP. It is also used for HTTPS if HTTPS
+	// is empty.
+	HTTP  string
+	HTTPS string
+	// SOCKS is the SOCKS proxy, used for everything the HTTP proxies are not.
+	SOCKS string
+	// SOCKSVersion is 4 or 5. It defaults to 5.
+	SOCKSVersion int
+	// RemoteDNS resolves host names through the SOCKS proxy. It is required
+	// whenever SOCKS is set, so that DNS lookups cannot leak.
+	RemoteDNS bool
+	// PACURL is the location of the proxy auto-config file for ProxyPAC.
+	PACURL string
+	// NoProxy lists hosts and domains which are connected to directly.
+	NoProxy []string
+}
+
+// Validate reports whether the configuration is complete and consistent.
+func (c *ProxyConfig) Validate() error {
+	switch c.Mode {
+	case ProxyNone, ProxySystem:
+		return nil
+	case ProxyManual:
+		if c.HTTP == "" && c.HTTPS == "" && c.SOCKS == "" {
+			return fmt.Errorf("manual proxy configuration needs an HTTP, HTTPS or SOCKS proxy")
+		}
+		for _, addr := range []string{c.HTTP, c.HTTPS, c.SOCKS} {
+			if addr == "" {
+				continue
+			}
+			if _, _, err := splitProxyAddr(addr); err != nil {
+				return err
+			}
+		}
+		if c.SOCKS != "" {
+			if c.SOCKSVersion != 0 && c.SOCKSVersion != 4 && c.SOCKSVersion != 5 {
+				return fmt.Errorf("invalid SOCKS version %d", c.SOCKSVersion)
+			}
+			if !c.RemoteDNS {
+				return fmt.Errorf("SOCKS proxy %s needs RemoteDNS, or DNS lookups would bypass it", c.SOCKS)
+			}
+		}
+		return nil
+	case ProxyPAC:
+		u, err := url.Parse(c.PACURL)
+		if err != nil {
+			return fmt.Errorf("invalid PAC URL: %w", err)
+		}
+		switch u.Scheme {
+		case "http", "https", "file", "data":
+			return nil
+		}
+		return fmt.Errorf("invalid PAC URL %q", c.PACURL)
+	}
+	return fmt.Errorf("unknown proxy mode %d", c.Mode)
+}
+
+// Prefs returns the Firefox preferences which apply the configuration. Every
+// proxy preference is set, so applying a new configuration replaces the old
+// one completely.
+func (c *ProxyConfig) Prefs() (map[string]interface{}, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	prefs := map[string]interface{}{
+		"network.proxy.type":                 proxyTypes[c.Mode],
+		"network.proxy.http":                 "",
+		"network.proxy.http_port":            0,
+		"network.proxy.ssl":                  "",
+		"network.proxy.ssl_port":             0,
+		"network.proxy.socks":                "",
+		"network.proxy.socks_port":           0,
+		"network.proxy.socks_version":        5,
+		"network.proxy.socks_remote_dns":     c.RemoteDNS,
+		"network.proxy.socks5_remote_dns":    c.RemoteDNS,
+		"network.proxy.share_proxy_settings": false,
+		"network.proxy.autoconfig_url":       c.PACURL,
+		"network.proxy.no_proxies_on":        strings.Join(c.NoProxy, ", "),
+		// Never fall back to a direct connection when the proxy is down.
+		"network.proxy.failover_direct": c.Mode == ProxyNone || c.Mode == ProxySystem,
+	}
+	if c.Mode != ProxyManual {
+		return prefs, nil
+	}
+	https := c.HTTPS
+	if https == "" {
+		https = c.HTTP
+	}
+	for prefix, addr := range map[string]string{"http": c.HTTP, "ssl": https, "socks": c.SOCKS} {
+		if addr == "" {
+			continue
+		}
+		host, port, _ := splitProxyAddr(addr)
+		prefs["network.proxy."+prefix] = host
+		prefs["network.proxy."+prefix+"_port"] = port
+	}
+	if c.SOCKSVersion != 0 {
+		prefs["network.proxy.socks_version"] = c.SOCKSVersion
+	}
+	return prefs, nil
+}
+
+func splitProxyAddr(addr string) (string, int, error) {
+	host, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid proxy address %q: %w", addr, err)
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil || port < 1 || port > 65535 || host == "" {
+		return "", 0, fmt.Errorf("invalid proxy address %q", addr)
+	}
+	return host, port, nil
+}
@@ -0,0 +1,39 @@
+package fcw
+
+import "testing"
+
+func TestProxyConfig(t *testing.T) {
+	invalid := []ProxyConfig{
+		{Mode: ProxyManual},
+		{Mode: ProxyManual, SOCKS: "127.0.0.1:9050"},
+		{Mode: ProxyManual, HTTP: "127.0.0.1"},
+		{Mode: ProxyManual, SOCKS: "127.0.0.1:9050", SOCKSVersion: 6, RemoteDNS: true},
+		{Mode: ProxyPAC, PACURL: "127.0.0.1/proxy.pac"},
+	}
+	for _, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", c)
+		}
+	}
+	c := ProxyConfig{Mode: ProxyManual, HTTP: "127.0.0.1:4444", SOCKS: "[::1]:9050", RemoteDNS: true}
+	prefs, err := c.Prefs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"network.proxy.type":             1,
+		"network.proxy.http":             "127.0.0.1",
+		"network.proxy.http_port":        4444,
+		"network.proxy.ssl":              "127.0.0.1",
+		"network.proxy.ssl_port":         4444,
+		"network.proxy.socks":            "::1",
+		"network.proxy.socks_port":       9050,
+		"network.proxy.socks_remote_dns": true,
+		"network.proxy.failover_direct":  false,
+	}
+	for name, value := range want {
+		if prefs[name] != value {
+			t.Errorf("%s = %v, want %v", name, prefs[name], value)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package fcw
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// scopeExtensionID is the ID of the generated extension which keeps a window
+// on its app's scope.
+const scopeExtensionID = "scope@eyedeekay.github.io"
+
+// scopeScript cancels top-level navigations outside the scope and hands them
+// to the handoff server, which opens them in the system's default browser. A
+// tab which was opened just for such a navigation is closed again.
+const scopeScript = `const scope = %s;
+const handoff = %s;
+
+function inScope(url) {
+  if (!/^https?:/.test(url)) {
+    return true;
+  }
+  return scope.some((prefix) => url.startsWith(prefix) || url + "/" === prefix);
+}
+
+browser.webRequest.onBeforeRequest.addListener(
+  (details) => {
+    if (inScope(details.url)) {
+      return {};
+    }
+    fetch(handoff, {
+      method: "POST",
+      body: new URLSearchParams({ url: details.url }),
+    });
+    if (details.tabId >= 0) {
+      browser.tabs.get(details.tabId).then((tab) => {
+        if (tab.url === "about:blank") {
+          browser.tabs.remove(tab.id);
+        }
+      });
+    }
+    return { cancel: true };
+  },
+  { urls: ["<all_urls>"], types: ["main_frame"] },
+  ["blocking"]
+);
+`
+
+// scopePrefixes turns a list of origins and URL prefixes into the prefixes a
+// URL must start with to be in scope. An origin like "https://example.com"
+// covers every page on that origin.
+func scopePrefixes(scope []string) ([]string, error) {
+	prefixes := make([]string, 0, len(scope))
+	for _, entry := range scope {
+		u, err := url.Parse(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scope %q: %w", entry, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("scope %q must be an http or https URL", entry)
+		}
+		u.Scheme = strings.ToLower(u.Scheme)
+		u.Host = strings.ToLower(u.Host)
+		if (u.Scheme == "http" && u.Port() == "80") || (u.Scheme == "https" && u.Port() == "443") {
+			u.Host = u.Hostname()
+		}
+		if u.Path == "" {
+			u.Path = "/"
+		}
+		prefixes = append(prefixes, u.String())
+	}
+	return prefixes, nil
+}
+
+// scopeHandoff receives out-of-scope URLs from the scope extension and opens
+// them in the system's default browser.
+type scopeHandoff struct {
+	*loopbackServer
+}
+
+func startScopeHandoff() (*scopeHandoff, error) {
+	ls, err := listenLoopback()
+	if err != nil {
+		return nil, err
+	}
+	h := &scopeHandoff{loopbackServer: ls}
+	ls.serve(h)
+	return h, nil
+}
+
+func (h *scopeHandoff) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != h.token+"/open" {
+		http.NotFound(w, r)
+		return
+	}
+	target, err := url.Parse(r.FormValue("url"))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+		http.Error(w, "invalid url", http.StatusBadRequest)
+		return
+	}
+	log.Println("Opening out-of-scope URL in the default browser", target)
+	go func() {
+		if err := openURL(target.String()); err != nil {
+			log.Println(err)
+		}
+	}()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// scopeExtension returns the extension which keeps the browser on prefixes.
+func scopeExtension(prefixes []string, handoff string) (*webExtension, error) {
+	scopeJSON, err := json.Marshal(prefixes)
+	if err != nil {
+		return nil, err
+	}
+	handoffJSON, err := json.Marshal(handoff)
+	if err != nil {
+		return nil, err
+	}
+	return &webExtension{
+		ID:          scopeExtensionID,
+		Name:        "App Scope",
+		Permissions: []string{"webRequest", "webRequestBlocking", "tabs", "<all_urls>"},
+		Files: map[string][]byte{
+			"background.js": []byte(fmt.Sprintf(scopeScript, scopeJSON, handoffJSON)),
+		},
+	}, nil
+}
@@ -0,0 +1,118 @@
+package fcw
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestScopeExtension(t *testing.T) {
+	prefixes, err := scopePrefixes([]string{"HTTPS://Example.com:443", "http://localhost:7657/i2ptunnel/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"https://example.com/", "http://localhost:7657/i2ptunnel/"}
+	if !reflect.DeepEqual(prefixes, want) {
+		t.Errorf("scopePrefixes = %v, want %v", prefixes, want)
+	}
+	if _, err := scopePrefixes([]string{"example.com"}); err == nil {
+		t.Error("scope without a scheme accepted")
+	}
+	ext, err := scopeExtension(prefixes, "http://127.0.0.1:1/token/open")
+	if err != nil {
+		t.Fatal(err)
+	}
+	xpi, err := ext.xpi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(xpi), int64(len(xpi)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest struct {
+		Background struct {
+			Scripts []string `json:"scripts"`
+		} `json:"background"`
+		Settings struct {
+			Gecko struct {
+				ID string `json:"id"`
+			} `json:"gecko"`
+		} `json:"browser_specific_settings"`
+	}
+	for _, f := range zr.File {
+		if f.Name != "manifest.json" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(r)
+		r.Close()
+		if err := json.Unmarshal(content, &manifest); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if manifest.Settings.Gecko.ID != scopeExtensionID || len(manifest.Background.Scripts) != 1 {
+		t.Errorf("unexpected manifest %+v", manifest)
+	}
+}
+
+func TestCheckUnsignedExtensions(t *testing.T) {
+	for _, c := range []struct {
+		channel, name string
+		refused       bool
+	}{
+		{"release", "Firefox", true},
+		{"beta", "Firefox", true},
+		{"esr", "Firefox", false},
+		{"nightly", "Firefox", false},
+		{"release", "LibreWolf", false},
+	} {
+		dir := t.TempDir()
+		for name, content := range map[string]string{
+			"firefox":                        "",
+			"defaults/pref/channel-prefs.js": `pref("app.update.channel", "` + c.channel + `");` + "\n",
+			"application.ini":                "[App]\nName=" + c.name + "\n",
+		} {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := checkUnsignedExtensions(filepath.Join(dir, "firefox"))
+		if refused := errors.Is(err, ErrUnsignedExtensions); refused != c.refused || (!refused && err != nil) {
+			t.Errorf("%s %s: checkUnsignedExtensions = %v", c.name, c.channel, err)
+		}
+	}
+	// Without channel-prefs.js the build cannot be told apart.
+	exe := filepath.Join(t.TempDir(), "firefox")
+	if err := os.WriteFile(exe, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkUnsignedExtensions(exe); err == nil {
+		t.Error("unknown build accepted")
+	}
+}
+
+func TestLaunchRefusesScope(t *testing.T) {
+	fakeFirefox(t)
+	dir := t.TempDir()
+	_, err := LaunchFirefox(dir, Options{Scope: []string{"https://example.com/"}}, "https://example.com/")
+	if err == nil {
+		t.Fatal("launched without a way to enforce Scope")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "extensions")); !os.IsNotExist(err) {
+		t.Errorf("scope extension installed after refusing: %v", err)
+	}
+}
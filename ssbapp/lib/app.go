@@ -19,13 +19,26 @@ import (
 // The private argument specifies whether to use private browsing mode.
 // The offline argument specifies whether to use offline mode.
 // The startURL argument is the URL to navigate to.
+// The scope arguments are origins or URL prefixes the browser is kept on;
+// links leading anywhere else are opened in the system's default browser.
+// Without them, the browser can navigate anywhere.
 // If the URL is invalid, the function will log an error and exit.
 // If the profile directory cannot be created, the function will log an error
 // and exit.
 // If the Firefox instance cannot be started, the function will log an error
 // and exit.
 // The function will wait for the browser to close before returning/terminating.
-func WebAppFunction(startURL, profileBase string, private, offline bool) {
+func WebAppFunction(startURL, profileBase string, private, offline bool, scope ...string) {
+	WebAppWithOptions(startURL, profileBase, fcw.Options{
+		Private: private,
+		Offline: offline,
+		Scope:   scope,
+	})
+}
+
+// WebAppWithOptions works like WebAppFunction, but takes the full set of
+// launch options. App mode is always turned on.
+func WebAppWithOptions(startURL, profileBase string, opts fcw.Options) {
 	if startURL == "" {
 		fmt.Fprintf(os.Stderr, "Error: -url flag is required\n")
 		flag.Usage()
@@ -50,7 +63,15 @@ func WebAppFunction(startURL, profileBase string, private, offline bool) {
 	}
 
 	// Create and configure Firefox instance
-	ui, err := fcw.WebAppFirefox(profileDir, private, offline, startURL)
+	opts.App = true
+	if opts.Startup == nil {
+		// The activity stream looks out of place without a URL bar.
+		opts.Startup = &fcw.StartupConfig{
+			Homepage: []string{startURL},
+			NewTab:   fcw.NewTabBlank,
+		}
+	}
+	ui, err := fcw.LaunchFirefox(profileDir, opts, startURL)
 	if err != nil {
 		log.Fatalf("Failed to start Firefox: %v", err)
 	}
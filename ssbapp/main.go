@@ -4,7 +4,9 @@ import (
 	"flag"
 	"os"
 	"path/filepath"
+	"strings"
 
+	fcw "github.com/eyedeekay/go-fpw"
 	ssb "github.com/eyedeekay/go-fpw/ssbapp/lib"
 )
 
@@ -14,11 +16,63 @@ func main() {
 	profileBase := flag.String("profiles", getDefaultProfileDir(), "Base directory for profiles")
 	private := flag.Bool("private", false, "Use private browsing mode")
 	offline := flag.Bool("offline", false, "Use offline mode")
+	scope := flag.String("scope", "", "Comma-separated origins or URL prefixes to keep the browser on")
+	noUpdates := flag.Bool("no-updates", false, "Disable Firefox updates and background services")
+	locale := flag.String("locale", "", "Language to present the app in, like de-DE")
+	timezone := flag.String("timezone", "", "Time zone to present the app in, like Europe/Berlin")
+	template := flag.String("template", "", "Profile directory or archive new profiles are cloned from")
+	downloads := flag.String("downloads", "", "Directory to save the app's downloads to")
+	newTab := flag.String("newtab", "blank", "What new tabs show: blank, app, or a URL")
+	allow := flag.String("allow", "", "Comma-separated hosts, optionally with ports, to allow; blocks all other network access")
 
 	flag.Parse()
 
+	opts := fcw.Options{
+		Private: *private,
+		Offline: *offline,
+		Scope:   splitList(*scope),
+
+		Template:       *template,
+		DisableUpdates: *noUpdates,
+	}
+	if *locale != "" || *timezone != "" {
+		opts.Locale = &fcw.LocaleConfig{Timezone: *timezone}
+		if *locale != "" {
+			opts.Locale.UILocale = *locale
+			opts.Locale.AcceptLanguages = []string{*locale}
+			opts.Locale.Spellcheck = *locale
+		}
+	}
+	opts.Startup = &fcw.StartupConfig{Homepage: []string{*startURL}}
+	switch *newTab {
+	case "blank":
+		opts.Startup.NewTab = fcw.NewTabBlank
+	case "app":
+		opts.Startup.NewTab = fcw.NewTabHomepage
+	default:
+		opts.Startup.NewTab = fcw.NewTabURL
+		opts.Startup.NewTabURL = *newTab
+	}
+	if *downloads != "" {
+		opts.Downloads = &fcw.DownloadConfig{Dir: *downloads}
+	}
+	if hosts := splitList(*allow); len(hosts) > 0 {
+		opts.Airgap = &fcw.OfflineConfig{Allow: hosts}
+	}
+
 	// Validate URL
-	ssb.WebAppFunction(*startURL, *profileBase, *private, *offline)
+	ssb.WebAppWithOptions(*startURL, *profileBase, opts)
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
 }
 
 // getDefaultProfileDir returns the default base directory for profiles
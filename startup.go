@@ -0,0 +1,150 @@
+package fcw
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// StartupPage selects what Firefox shows when it starts.
+type StartupPage int
+
+const (
+	// StartupDefault leaves Firefox's setting alone.
+	StartupDefault StartupPage = iota
+	// StartupHomepage opens the home pages.
+	StartupHomepage
+	// StartupRestore restores the previous session.
+	StartupRestore
+	// StartupBlank opens a blank page.
+	StartupBlank
+)
+
+// browser.startup.page values for each StartupPage.
+var startupPages = map[StartupPage]int{
+	StartupBlank:    0,
+	StartupHomepage: 1,
+	StartupRestore:  3,
+}
+
+// NewTabPage selects what a new tab shows.
+type NewTabPage int
+
+const (
+	// NewTabDefault leaves Firefox's new tab page alone.
+	NewTabDefault NewTabPage = iota
+	// NewTabBlank shows a blank page.
+	NewTabBlank
+	// NewTabHomepage shows the first home page, which for a site-specific
+	// browser is usually the app. Firefox builds which do not load unsigned
+	// extensions show a blank page instead.
+	NewTabHomepage
+	// NewTabURL shows StartupConfig.NewTabURL, with the same limits as
+	// NewTabHomepage.
+	NewTabURL
+)
+
+// newTabExtensionID is the ID of the generated extension which replaces the
+// new tab page.
+const newTabExtensionID = "newtab@eyedeekay.github.io"
+
+// StartupConfig describes the home page, what is shown on startup and what a
+// new tab shows.
+type StartupConfig struct {
+	// Homepage lists the home pages. Several are opened in separate tabs.
+	Homepage []string
+	Startup  StartupPage
+	NewTab   NewTabPage
+	// NewTabURL is the page new tabs show with NewTabURL.
+	NewTabURL string
+}
+
+// Validate reports whether the configuration is complete and consistent.
+func (c *StartupConfig) Validate() error {
+	for _, u := range c.Homepage {
+		if err := validPageURL(u); err != nil {
+			return err
+		}
+	}
+	if c.Startup < StartupDefault || c.Startup > StartupBlank {
+		return fmt.Errorf("unknown startup page %d", c.Startup)
+	}
+	if c.Startup == StartupHomepage && len(c.Homepage) == 0 {
+		return fmt.Errorf("starting with the home page needs a home page")
+	}
+	switch c.NewTab {
+	case NewTabDefault, NewTabBlank:
+	case NewTabHomepage:
+		if len(c.Homepage) == 0 {
+			return fmt.Errorf("showing the home page in new tabs needs a home page")
+		}
+	case NewTabURL:
+		if err := validPageURL(c.NewTabURL); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown new tab page %d", c.NewTab)
+	}
+	return nil
+}
+
+func validPageURL(page string) error {
+	u, err := url.Parse(page)
+	if err != nil || u.Scheme == "" || strings.Contains(page, "|") {
+		return fmt.Errorf("invalid page URL %q", page)
+	}
+	return nil
+}
+
+// Prefs returns the Firefox preferences which apply the configuration.
+func (c *StartupConfig) Prefs() (map[string]interface{}, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	prefs := map[string]interface{}{}
+	if len(c.Homepage) > 0 {
+		prefs["browser.startup.homepage"] = strings.Join(c.Homepage, "|")
+	}
+	if page, ok := startupPages[c.Startup]; ok {
+		prefs["browser.startup.page"] = page
+	}
+	if c.NewTab != NewTabDefault {
+		// Firefox builds which ignore the unsigned new tab extension
+		// show a blank page instead of the activity stream.
+		prefs["browser.newtabpage.enabled"] = false
+	}
+	return prefs, nil
+}
+
+// newTabPage returns the URL new tabs are sent to, or "" if Firefox's own
+// setting handles them.
+func (c *StartupConfig) newTabPage() string {
+	switch c.NewTab {
+	case NewTabHomepage:
+		return c.Homepage[0]
+	case NewTabURL:
+		return c.NewTabURL
+	}
+	return ""
+}
+
+// newTabExtension returns the extension which sends new tabs to page. Firefox
+// has no preference for a custom new tab page any more.
+func newTabExtension(page string) (*webExtension, error) {
+	pageJSON, err := json.Marshal(page)
+	if err != nil {
+		return nil, err
+	}
+	return &webExtension{
+		ID:   newTabExtensionID,
+		Name: "New Tab Page",
+		Manifest: map[string]interface{}{
+			"chrome_url_overrides": map[string]string{"newtab": "newtab.html"},
+		},
+		Files: map[string][]byte{
+			"newtab.html": []byte(`<!DOCTYPE html><html><head><meta charset="utf-8"><title></title><script src="newtab.js"></script></head><body></body></html>`),
+			"newtab.js":   []byte("location.replace(" + string(pageJSON) + ");\n"),
+		},
+	}, nil
+}
@@ -0,0 +1,28 @@
+package fcw
+
+import "testing"
+
+func TestStartupConfigNewTab(t *testing.T) {
+	for _, c := range []StartupConfig{
+		{NewTab: NewTabBlank},
+		{Homepage: []string{"https://app.example.com/"}, NewTab: NewTabHomepage},
+		{NewTab: NewTabURL, NewTabURL: "https://app.example.com/new"},
+	} {
+		prefs, err := c.Prefs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Without the new tab extension, new tabs must not fall back to
+		// the activity stream.
+		if prefs["browser.newtabpage.enabled"] != false {
+			t.Errorf("%+v: browser.newtabpage.enabled = %v", c, prefs["browser.newtabpage.enabled"])
+		}
+	}
+	prefs, err := (&StartupConfig{}).Prefs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := prefs["browser.newtabpage.enabled"]; ok {
+		t.Error("the default new tab page is changed")
+	}
+}
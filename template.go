@@ -0,0 +1,76 @@
+package fcw
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CloneFrom fills the profile from template, which is a profile directory or
+// a tar.gz or zip archive of one. Locks, caches, crash reports and the saved
+// session are left out. The profile directory is created if it does not
+// exist, and must be empty.
+func (p *Profile) CloneFrom(template string) error {
+	if err := p.Create(); err != nil {
+		return err
+	}
+	empty, err := p.empty()
+	if err != nil {
+		return err
+	}
+	if !empty {
+		return &ProfileError{Path: p.dir, Err: ErrProfileNotEmpty}
+	}
+	if err := p.cloneFrom(template); err != nil {
+		p.clear()
+		return fmt.Errorf("cloning %s: %w", template, err)
+	}
+	return nil
+}
+
+func (p *Profile) cloneFrom(template string) error {
+	info, err := os.Stat(template)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return copyTree(template, p.dir, transient)
+	}
+	format, ok := archiveFormat(template)
+	if !ok {
+		return fmt.Errorf("not a directory, tar.gz or zip archive")
+	}
+	if format == ArchiveZip {
+		zr, err := zip.OpenReader(template)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		return extractZip(&zr.Reader, p.dir, transient)
+	}
+	f, err := os.Open(template)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return extractTarGz(f, p.dir, transient)
+}
+
+// empty reports whether the profile directory has nothing in it.
+func (p *Profile) empty() (bool, error) {
+	entries, err := os.ReadDir(p.dir)
+	if err != nil {
+		return false, profileError(p.dir, err)
+	}
+	return len(entries) == 0, nil
+}
+
+// clear removes everything inside the profile directory, after a clone
+// failed half way.
+func (p *Profile) clear() {
+	entries, _ := os.ReadDir(p.dir)
+	for _, entry := range entries {
+		os.RemoveAll(filepath.Join(p.dir, entry.Name()))
+	}
+}
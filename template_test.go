@@ -0,0 +1,102 @@
+package fcw
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var templateFiles = map[string]string{
+	"prefs.js":                         "user_pref(\"browser.startup.page\", 3);\n",
+	"chrome/userChrome.css":            "#nav-bar { display: none; }\n",
+	"lock":                             "",
+	"cache2/entries/ABCD":              "cached",
+	"sessionstore-backups/recovery.js": "{}",
+	manifestName:                       `{"files": {"chrome/userChrome.css": null}}`,
+}
+
+func TestCloneFromDirectory(t *testing.T) {
+	template := t.TempDir()
+	for name, content := range templateFiles {
+		path := filepath.Join(template, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dir := filepath.Join(t.TempDir(), "app")
+	if err := NewProfile(dir).CloneFrom(template); err != nil {
+		t.Fatal(err)
+	}
+	checkClone(t, dir)
+	if err := NewProfile(dir).CloneFrom(template); !errors.Is(err, ErrProfileNotEmpty) {
+		t.Errorf("cloning into a used profile = %v, want ErrProfileNotEmpty", err)
+	}
+}
+
+func TestCloneFromArchive(t *testing.T) {
+	var tgz bytes.Buffer
+	gz := gzip.NewWriter(&tgz)
+	tw := tar.NewWriter(gz)
+	for name, content := range templateFiles {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	archive := filepath.Join(t.TempDir(), "golden.tar.gz")
+	if err := os.WriteFile(archive, tgz.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := NewProfile(dir).CloneFrom(archive); err != nil {
+		t.Fatal(err)
+	}
+	checkClone(t, dir)
+
+	var evil bytes.Buffer
+	zw := zip.NewWriter(&evil)
+	w, _ := zw.Create("prefs.js")
+	w.Write([]byte("\n"))
+	w, _ = zw.Create("../escaped.js")
+	w.Write([]byte("\n"))
+	zw.Close()
+	archive = filepath.Join(t.TempDir(), "evil.zip")
+	if err := os.WriteFile(archive, evil.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	parent := t.TempDir()
+	dir = filepath.Join(parent, "app")
+	if err := NewProfile(dir).CloneFrom(archive); err == nil {
+		t.Error("cloning an archive with an entry outside the profile succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(parent, "escaped.js")); !os.IsNotExist(err) {
+		t.Error("archive entry was written outside the profile")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("failed clone left %d files behind", len(entries))
+	}
+}
+
+func checkClone(t *testing.T, dir string) {
+	t.Helper()
+	for name, content := range templateFiles {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if transient(name) {
+			if err == nil {
+				t.Errorf("transient %s was cloned", name)
+			}
+			continue
+		}
+		if string(data) != content {
+			t.Errorf("%s = %q, %v; want %q", name, data, err, content)
+		}
+	}
+}
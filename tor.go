@@ -0,0 +1,69 @@
+package fcw
+
+// DefaultTorSOCKS is the address of a tor daemon's SOCKS port.
+const DefaultTorSOCKS = "127.0.0.1:9050"
+
+// TorConfig describes how to reach a tor daemon.
+type TorConfig struct {
+	// SOCKS is the daemon's SOCKS5 port. It defaults to DefaultTorSOCKS.
+	SOCKS string
+}
+
+func (c TorConfig) socks() string {
+	if c.SOCKS == "" {
+		return DefaultTorSOCKS
+	}
+	return c.SOCKS
+}
+
+// torPrefs let ".onion" addresses load, and turn on Firefox's fingerprinting
+// resistance so the browser looks alike to every site it visits over Tor.
+var torPrefs = map[string]interface{}{
+	"network.dns.blockDotOnion":                 false,
+	"browser.fixup.domainsuffixwhitelist.onion": true,
+	"keyword.enabled":                           false,
+	"privacy.resistFingerprinting":              true,
+	"privacy.resistFingerprinting.letterboxing": true,
+	"privacy.firstparty.isolate":                true,
+	"webgl.disabled":                            true,
+	"media.navigator.enabled":                   false,
+	"dom.webaudio.enabled":                      false,
+	"privacy.trackingprotection.enabled":        true,
+}
+
+// Apply configures opts to browse through tor's SOCKS5 port, with every DNS
+// lookup made by tor, WebRTC, prefetching and other leaks disabled, and
+// fingerprinting resistance turned on. DNS-over-HTTPS is turned off, so it
+// returns an error if opts.DoH is set.
+func (c TorConfig) Apply(opts *Options) error {
+	if opts.DoH != nil {
+		return errOverlayDoH
+	}
+	proxy := &ProxyConfig{
+		Mode:         ProxyManual,
+		SOCKS:        c.socks(),
+		SOCKSVersion: 5,
+		RemoteDNS:    true,
+	}
+	if err := proxy.Validate(); err != nil {
+		return err
+	}
+	opts.Proxy = proxy
+	opts.Prefs = overlayPrefs([]map[string]interface{}{leakPrefs, torPrefs}, opts.Prefs)
+	return nil
+}
+
+// TorFirefox sets up a new Firefox instance for browsing through a running
+// tor daemon, and creates the profile directory if it does not already exist.
+// It is meant for when Tor Browser is not available. It refuses to launch,
+// returning an error wrapping ErrProxyUnreachable, when the SOCKS port is not
+// accepting connections.
+func TorFirefox(userdir string, cfg TorConfig, opts Options, args ...string) (UI, error) {
+	if err := cfg.Apply(&opts); err != nil {
+		return nil, err
+	}
+	if err := WaitForProxy(cfg.socks(), 0); err != nil {
+		return nil, err
+	}
+	return LaunchFirefox(userdir, opts, args...)
+}
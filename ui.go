@@ -31,7 +31,6 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
-	"strconv"
 	"strings"
 	"sync"
 )
@@ -56,16 +55,21 @@ func PromptDownload() {
 	}
 
 	// Open download page
-	url := "https://www.mozilla.org/firefox/"
+	openURL("https://www.mozilla.org/firefox/")
+}
+
+// openURL opens url in the system's default browser.
+func openURL(url string) error {
 	switch runtime.GOOS {
 	case "linux":
-		exec.Command("xdg-open", url).Run()
+		return exec.Command("xdg-open", url).Run()
 	case "darwin":
-		exec.Command("open", url).Run()
+		return exec.Command("open", url).Run()
 	case "windows":
 		r := strings.NewReplacer("&", "^&")
-		exec.Command("cmd", "/c", "start", r.Replace(url)).Run()
+		return exec.Command("cmd", "/c", "start", r.Replace(url)).Run()
 	}
+	return fmt.Errorf("don't know how to open URLs on %s", runtime.GOOS)
 }
 
 type firefox struct {
@@ -85,6 +89,9 @@ type ui struct {
 	*firefox
 	done   chan struct{}
 	tmpDir string
+	// closers release resources which live as long as the browser, like
+	// local servers. They run in Close once Firefox has exited.
+	closers []func() error
 }
 
 func (u *ui) Log() string {
@@ -99,17 +106,25 @@ func (u *ui) Done() <-chan struct{} {
 	return u.done
 }
 
+// Close stops Firefox and reverts the changes recorded in the profile's
+// manifest, see Profile.Restore.
 func (u *ui) Close() error {
-	defer DeAppifyUserJS(u.firefox.profileDir)
 	// ignore err, as the firefox process might be already dead, when user close the window.
 	u.firefox.kill()
 	<-u.done
+	for _, closer := range u.closers {
+		if err := closer(); err != nil {
+			log.Println(err)
+		}
+	}
+	u.closers = nil
 	if u.tmpDir != "" {
 		if err := os.RemoveAll(u.tmpDir); err != nil {
 			return err
 		}
+		return nil
 	}
-	return nil
+	return NewProfile(u.firefox.profileDir).Restore()
 }
 
 var firefoxArgs = []string{
@@ -130,25 +145,6 @@ func randir() string {
 	return "1"
 }
 
-func directory(dir string) string {
-	i := 0
-	if file, err := os.Stat(dir); os.IsNotExist(err) {
-		os.MkdirAll(dir, 0o755)
-	} else {
-		if !file.IsDir() {
-			for {
-				dir = dir + "-" + strconv.Itoa(i)
-				i++
-				if _, err := os.Stat(dir); os.IsNotExist(err) {
-					os.MkdirAll(dir, 0o755)
-					return dir
-				}
-			}
-		}
-	}
-	return dir
-}
-
 func (f *firefox) CertManager() (*CertManager, error) {
 	if f.certManager == nil {
 		cm, err := NewCertManager(f.target)
@@ -160,8 +156,19 @@ func (f *firefox) CertManager() (*CertManager, error) {
 	return f.certManager, nil
 }
 
-// NewFirefox creates a new instance of the Firefox manager.
+// NewFirefox creates a new instance of the Firefox manager. A non-empty dir is
+// created if it does not exist, see Profile.Create; an empty one is replaced
+// by a temporary profile.
 func NewFirefox(url, dir string, width, height int, customArgs ...string) (UI, error) {
+	u, err := newUI(url, dir, width, height, nil, customArgs...)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// newUI starts Firefox like NewFirefox, with env added to its environment.
+func newUI(url, dir string, width, height int, env []string, customArgs ...string) (*ui, error) {
 	tmpDir := ""
 	if dir == "" {
 		name, err := ioutil.TempDir("", "ffox")
@@ -169,8 +176,8 @@ func NewFirefox(url, dir string, width, height int, customArgs ...string) (UI, e
 			return nil, err
 		}
 		dir, tmpDir = name, name
-	} else {
-		dir = directory(dir)
+	} else if err := NewProfile(dir).Create(); err != nil {
+		return nil, err
 	}
 	args := append(firefoxArgs, "--profile")
 	args = append(args, dir)
@@ -182,7 +189,7 @@ func NewFirefox(url, dir string, width, height int, customArgs ...string) (UI, e
 	// args = append(args, "--remote-debugging-port=0")
 	log.Println(FirefoxExecutable(), args)
 
-	firefox, err := newFirefoxWithArgs(FirefoxExecutable(), args...)
+	firefox, err := newFirefoxWithArgs(FirefoxExecutable(), env, args...)
 	done := make(chan struct{})
 	if err != nil {
 		return nil, err
@@ -207,7 +214,7 @@ func (c *firefox) kill() error {
 	return nil
 }
 
-func newFirefoxWithArgs(firefoxBinary string, args ...string) (*firefox, error) {
+func newFirefoxWithArgs(firefoxBinary string, env []string, args ...string) (*firefox, error) {
 	// The first two IDs are used internally during the initialization
 	if firefoxBinary == "" {
 		PromptDownload()
@@ -219,6 +226,9 @@ func newFirefoxWithArgs(firefoxBinary string, args ...string) (*firefox, error)
 
 	// Start firefox process
 	c.cmd = exec.Command(firefoxBinary, args...)
+	if len(env) > 0 {
+		c.cmd.Env = append(os.Environ(), env...)
+	}
 	if err := c.cmd.Start(); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,97 @@
+package fcw
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// noUpdatePrefs stop Firefox from updating itself or its add-ons, polling
+// remote settings, scheduling background tasks and running the default
+// browser agent.
+var noUpdatePrefs = map[string]interface{}{
+	"app.update.auto":                             false,
+	"app.update.enabled":                          false,
+	"app.update.checkInstallTime":                 false,
+	"app.update.service.enabled":                  false,
+	"app.update.staging.enabled":                  false,
+	"app.update.background.enabled":               false,
+	"app.update.background.scheduling.enabled":    false,
+	"extensions.update.enabled":                   false,
+	"extensions.update.autoUpdateDefault":         false,
+	"extensions.systemAddon.update.enabled":       false,
+	"browser.search.update":                       false,
+	"services.settings.poll_interval":             2147483647,
+	"messaging-system.rsexperimentloader.enabled": false,
+	"browser.region.update.enabled":               false,
+	"default-browser-agent.enabled":               false,
+	"browser.shell.checkDefaultBrowser":           false,
+}
+
+// noUpdatePolicies do the same as noUpdatePrefs for the whole installation,
+// including the parts which run before or outside a profile.
+func noUpdatePolicies() *Policies {
+	no := false
+	return &Policies{
+		DisableAppUpdate:           true,
+		AppAutoUpdate:              &no,
+		BackgroundAppUpdate:        &no,
+		ExtensionUpdate:            &no,
+		DisableDefaultBrowserAgent: true,
+		DisableSystemAddonUpdate:   true,
+	}
+}
+
+// InstallDir returns the directory Firefox loads its distribution files and
+// default preferences from, given the path to its executable.
+func InstallDir(executable string) (string, error) {
+	if executable == "" {
+		return "", fmt.Errorf("Firefox not found.")
+	}
+	path, err := filepath.EvalSymlinks(executable)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Dir(path)
+	if runtime.GOOS == "darwin" && strings.HasSuffix(dir, filepath.Join("Contents", "MacOS")) {
+		dir = filepath.Join(filepath.Dir(dir), "Resources")
+	}
+	return dir, nil
+}
+
+// portablePath finds the portable install DisableUpdates may change. It is a
+// variable so tests can stand in a Firefox of their own.
+var portablePath = PortablePath
+
+// portable reports whether the Firefox at executable is the portable install
+// found next to this program, rather than one shared with the rest of the
+// system.
+func portable(executable string) bool {
+	path := portablePath()
+	if path == "false" {
+		return false
+	}
+	dir, err := InstallDir(path)
+	if err != nil {
+		return false
+	}
+	installDir, err := InstallDir(executable)
+	return err == nil && installDir == dir
+}
+
+// DisableUpdates turns off self-updates and background services in the
+// installation of the Firefox at executable, through its policies.json. It
+// only changes portable installs found by PortablePath, and returns an error
+// for any other, since the policies would apply to every profile using it
+// and are not undone afterwards.
+func DisableUpdates(executable string) error {
+	dir, err := InstallDir(executable)
+	if err != nil {
+		return err
+	}
+	if !portable(executable) {
+		return fmt.Errorf("%s is not a portable install", dir)
+	}
+	return noUpdatePolicies().Merge(dir)
+}
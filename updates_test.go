@@ -0,0 +1,71 @@
+package fcw
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDisableUpdatesPrefs(t *testing.T) {
+	l, err := Options{DisableUpdates: true}.prepare()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.close()
+	for name, want := range noUpdatePrefs {
+		if v := l.prefs[name]; v != want {
+			t.Errorf("%s = %v, want %v", name, v, want)
+		}
+	}
+}
+
+func TestDisableUpdatesPortable(t *testing.T) {
+	fakeFirefox(t)
+	exe := FirefoxExecutable()
+	other := filepath.Join(t.TempDir(), "firefox")
+	if err := os.WriteFile(other, nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	saved := portablePath
+	defer func() { portablePath = saved }()
+
+	// A system-wide install is left alone, and only gets the preferences.
+	portablePath = func() string { return other }
+	if err := DisableUpdates(exe); err == nil {
+		t.Error("DisableUpdates changed an install which is not portable")
+	}
+	dir := t.TempDir()
+	ui, err := LaunchFirefox(dir, Options{DisableUpdates: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ui.Close(); err != nil {
+		t.Fatal(err)
+	}
+	installDir, err := InstallDir(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(installDir, "distribution")); !os.IsNotExist(err) {
+		t.Errorf("distribution directory written to a shared install: %v", err)
+	}
+	if v, _, _ := NewProfile(dir).GetPref("app.update.auto"); v != false {
+		t.Errorf("app.update.auto = %v, want false", v)
+	}
+
+	// The portable install gets the policies as well.
+	portablePath = func() string { return exe }
+	if err := DisableUpdates(exe); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := readPoliciesFile(PoliciesPath(installDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies, _ := doc["policies"].(map[string]interface{})
+	for _, name := range []string{"DisableAppUpdate", "DisableSystemAddonUpdate"} {
+		if policies[name] != true {
+			t.Errorf("policies.json lacks %s: %v", name, doc)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package fcw
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FirefoxVersion is the version and build of a Firefox.
+type FirefoxVersion struct {
+	// Version is the version number, like "128.0.3" or "130.0b2".
+	Version string
+	// BuildID is the build timestamp, like "20240708123456".
+	BuildID string
+}
+
+func (v FirefoxVersion) String() string {
+	if v.BuildID == "" {
+		return v.Version
+	}
+	return v.Version + " (" + v.BuildID + ")"
+}
+
+// Compare returns -1, 0 or 1 as v is older than, the same as or newer than o.
+// Builds of the same version are ordered by their build IDs.
+func (v FirefoxVersion) Compare(o FirefoxVersion) int {
+	if c := compareVersions(v.Version, o.Version); c != 0 {
+		return c
+	}
+	return strings.Compare(v.BuildID, o.BuildID)
+}
+
+// compareVersions compares Mozilla version numbers part by part. A part is a
+// number with an optional suffix; a suffix like "a1" or "b2" marks a
+// pre-release, which is older than the release. The "esr" suffix is ignored.
+func compareVersions(a, b string) int {
+	as := strings.Split(strings.TrimSuffix(a, "esr"), ".")
+	bs := strings.Split(strings.TrimSuffix(b, "esr"), ".")
+	for len(as) < len(bs) {
+		as = append(as, "0")
+	}
+	for len(bs) < len(as) {
+		bs = append(bs, "0")
+	}
+	for i := range as {
+		an, asuf := splitVersionPart(as[i])
+		bn, bsuf := splitVersionPart(bs[i])
+		switch {
+		case an != bn:
+			if an < bn {
+				return -1
+			}
+			return 1
+		case asuf == bsuf:
+		case asuf == "":
+			return 1
+		case bsuf == "":
+			return -1
+		default:
+			return strings.Compare(asuf, bsuf)
+		}
+	}
+	return 0
+}
+
+func splitVersionPart(part string) (int, string) {
+	i := 0
+	for i < len(part) && part[i] >= '0' && part[i] <= '9' {
+		i++
+	}
+	n, _ := strconv.Atoi(part[:i])
+	return n, part[i:]
+}
+
+// LastFirefoxVersion returns the version of Firefox which last used the
+// profile, from its compatibility.ini. ok is false for profiles Firefox has
+// never used.
+func (p *Profile) LastFirefoxVersion() (v FirefoxVersion, ok bool, err error) {
+	last := p.lastVersion()
+	if last == "" {
+		return FirefoxVersion{}, false, nil
+	}
+	// LastVersion is "version_buildid/buildid".
+	i := strings.Index(last, "_")
+	if i < 0 {
+		return FirefoxVersion{}, false, fmt.Errorf("compatibility.ini: invalid LastVersion %q", last)
+	}
+	v.Version = last[:i]
+	v.BuildID = strings.SplitN(last[i+1:], "/", 2)[0]
+	return v, true, nil
+}
+
+// InstalledFirefoxVersion returns the version of the Firefox at executable,
+// from the application.ini next to it.
+func InstalledFirefoxVersion(executable string) (FirefoxVersion, error) {
+	dir, err := InstallDir(executable)
+	if err != nil {
+		return FirefoxVersion{}, err
+	}
+	ini, err := readINI(filepath.Join(dir, "application.ini"))
+	if err != nil {
+		return FirefoxVersion{}, err
+	}
+	for _, section := range ini {
+		if section.name == "App" {
+			version, _ := section.get("Version")
+			buildID, _ := section.get("BuildID")
+			if version != "" {
+				return FirefoxVersion{Version: version, BuildID: buildID}, nil
+			}
+		}
+	}
+	return FirefoxVersion{}, fmt.Errorf("application.ini: no version")
+}
+
+// DowngradeError is returned when a profile was last used by a newer Firefox
+// than the one it would be launched with, which Firefox refuses by default.
+type DowngradeError struct {
+	// Profile is the profile directory.
+	Profile string
+	// ProfileVersion is the Firefox which last used the profile.
+	ProfileVersion FirefoxVersion
+	// FirefoxVersion is the Firefox it would be launched with.
+	FirefoxVersion FirefoxVersion
+}
+
+func (e *DowngradeError) Error() string {
+	return fmt.Sprintf("profile %s was last used by Firefox %s, which is newer than Firefox %s", e.Profile, e.ProfileVersion, e.FirefoxVersion)
+}
+
+// CheckVersion returns a *DowngradeError if the profile was last used by a
+// newer Firefox than the one at executable.
+func (p *Profile) CheckVersion(executable string) error {
+	last, ok, err := p.LastFirefoxVersion()
+	if err != nil || !ok {
+		return err
+	}
+	installed, err := InstalledFirefoxVersion(executable)
+	if err != nil {
+		return err
+	}
+	if last.Compare(installed) > 0 {
+		return &DowngradeError{Profile: p.dir, ProfileVersion: last, FirefoxVersion: installed}
+	}
+	return nil
+}
+
+// DowngradePolicy is what LaunchFirefox does with a profile last used by a
+// newer Firefox.
+type DowngradePolicy int
+
+const (
+	// DowngradeRefuse returns a *DowngradeError.
+	DowngradeRefuse DowngradePolicy = iota
+	// DowngradeAllow launches anyway with --allow-downgrade. Data the newer
+	// Firefox wrote may not be readable.
+	DowngradeAllow
+	// DowngradeFreshProfile moves the profile aside, next to it, and
+	// starts over with a new one, cloned from Options.Template if it is
+	// set. The profile stays where it is if Firefox is using it, or if the
+	// template was last used by a newer Firefox too.
+	DowngradeFreshProfile
+)
+
+// handleDowngrade applies policy to the profile, and returns the extra
+// arguments Firefox needs. A fresh profile is cloned from template, if it is
+// set, which must not be from a newer Firefox as well.
+func (p *Profile) handleDowngrade(policy DowngradePolicy, executable, template string) ([]string, error) {
+	err := p.CheckVersion(executable)
+	var derr *DowngradeError
+	if !errors.As(err, &derr) {
+		if err != nil {
+			// Without both versions, Firefox has to decide.
+			log.Println("Could not check the profile's Firefox version:", err)
+		}
+		return nil, nil
+	}
+	switch policy {
+	case DowngradeAllow:
+		return []string{"--allow-downgrade"}, nil
+	case DowngradeFreshProfile:
+		if err := p.checkUnlocked(); err != nil {
+			return nil, err
+		}
+		// The template is cloned next to the profile first, so a template
+		// which is too new leaves the profile where it is.
+		staging := p.dir + ".fpw-clone"
+		if template != "" {
+			log.Println("Cloning profile from", template)
+			os.RemoveAll(staging)
+			fresh := NewProfile(staging)
+			if err := fresh.CloneFrom(template); err != nil {
+				os.RemoveAll(staging)
+				return nil, err
+			}
+			if err := fresh.CheckVersion(executable); err != nil {
+				os.RemoveAll(staging)
+				return nil, err
+			}
+		}
+		aside := fmt.Sprintf("%s-firefox-%s-%s", p.dir, derr.ProfileVersion.Version, time.Now().Format("20060102150405"))
+		if err := os.Rename(p.dir, aside); err != nil {
+			os.RemoveAll(staging)
+			return nil, err
+		}
+		log.Println(derr, "- moved it to", aside)
+		if template == "" {
+			return nil, p.Create()
+		}
+		return nil, os.Rename(staging, p.dir)
+	}
+	return nil, derr
+}
@@ -0,0 +1,130 @@
+package fcw
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want int
+	}{
+		{"128.0", "128.0", 0},
+		{"128.0.1", "128.0", 1},
+		{"128.0", "129.0", -1},
+		{"130.0a1", "130.0b2", -1},
+		{"130.0b2", "130.0", -1},
+		{"115.12.0esr", "115.12.0", 0},
+		{"99.0", "100.0", -1},
+	} {
+		if got := compareVersions(c.a, c.b); got != c.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestDowngrade(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("application.ini lives in the app bundle on macOS")
+	}
+	install := t.TempDir()
+	executable := filepath.Join(install, "firefox")
+	if err := os.WriteFile(executable, nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(install, "application.ini"), []byte("[App]\nVendor=Mozilla\nName=Firefox\nVersion=115.12.0\nBuildID=20240604000000\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "app")
+	p := NewProfile(dir)
+	if err := p.Create(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CheckVersion(executable); err != nil {
+		t.Errorf("CheckVersion of an unused profile = %v", err)
+	}
+	compat := "[Compatibility]\nLastVersion=128.0_20240708000000/20240708000000\n"
+	if err := os.WriteFile(filepath.Join(dir, "compatibility.ini"), []byte(compat), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var derr *DowngradeError
+	if err := p.CheckVersion(executable); !errors.As(err, &derr) || derr.ProfileVersion.Version != "128.0" {
+		t.Fatalf("CheckVersion = %v, want a DowngradeError from 128.0", err)
+	}
+	if args, err := p.handleDowngrade(DowngradeAllow, executable, ""); err != nil || len(args) != 1 || args[0] != "--allow-downgrade" {
+		t.Errorf("DowngradeAllow = %v, %v", args, err)
+	}
+	if _, err := p.handleDowngrade(DowngradeFreshProfile, executable, ""); err != nil {
+		t.Fatal(err)
+	}
+	if empty, err := p.empty(); err != nil || !empty {
+		t.Errorf("profile is not fresh after DowngradeFreshProfile: %v", err)
+	}
+	moved, _ := filepath.Glob(dir + "-firefox-128.0-*")
+	if len(moved) != 1 {
+		t.Fatalf("old profile moved to %v", moved)
+	}
+
+	// A fresh profile is cloned from the template again, unless the
+	// template is from a newer Firefox too. Profiles moved aside within the
+	// same second would get the same name.
+	clearMoved := func() {
+		moved, _ := filepath.Glob(dir + "-firefox-128.0-*")
+		for _, m := range moved {
+			os.RemoveAll(m)
+		}
+	}
+	clearMoved()
+	template := t.TempDir()
+	if err := os.WriteFile(filepath.Join(template, "prefs.js"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "compatibility.ini"), []byte(compat), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.handleDowngrade(DowngradeFreshProfile, executable, template); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "prefs.js")); err != nil {
+		t.Errorf("fresh profile was not cloned from the template: %v", err)
+	}
+	clearMoved()
+	if err := os.WriteFile(filepath.Join(template, "compatibility.ini"), []byte(compat), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "compatibility.ini"), []byte(compat), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.handleDowngrade(DowngradeFreshProfile, executable, template); !errors.As(err, &derr) {
+		t.Errorf("DowngradeFreshProfile with a newer template = %v, want a DowngradeError", err)
+	}
+	// Nothing is moved when the template is too new, or the profile is in
+	// use.
+	checkInPlace := func(what string) {
+		t.Helper()
+		if _, err := os.Stat(filepath.Join(dir, "compatibility.ini")); err != nil {
+			t.Errorf("%s: profile was replaced: %v", what, err)
+		}
+		if moved, _ := filepath.Glob(dir + "-firefox-128.0-*"); len(moved) != 0 {
+			t.Errorf("%s: profile moved to %v", what, moved)
+		}
+		if _, err := os.Stat(dir + ".fpw-clone"); !os.IsNotExist(err) {
+			t.Errorf("%s: staged clone left behind: %v", what, err)
+		}
+	}
+	checkInPlace("newer template")
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink("127.0.0.1:+"+strconv.Itoa(os.Getpid()), filepath.Join(dir, "lock")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.handleDowngrade(DowngradeFreshProfile, executable, ""); !errors.Is(err, ErrProfileLocked) {
+			t.Errorf("DowngradeFreshProfile of a profile in use = %v, want ErrProfileLocked", err)
+		}
+		checkInPlace("profile in use")
+	}
+}
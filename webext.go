@@ -0,0 +1,140 @@
+package fcw
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// ErrUnsignedExtensions is returned when an option needs a generated, unsigned
+// extension, but the Firefox being launched is a release or beta build, which
+// never loads them.
+var ErrUnsignedExtensions = errors.New("this Firefox build does not load unsigned extensions")
+
+// webExtension is an extension generated at launch time. It is unsigned, so
+// Firefox only loads it when xpinstall.signatures.required can be turned off:
+// in ESR, Developer Edition, Nightly, unbranded builds and forks like
+// LibreWolf. Release builds ignore it.
+type webExtension struct {
+	ID          string
+	Name        string
+	Permissions []string
+	// Manifest holds extra manifest.json keys.
+	Manifest map[string]interface{}
+	// Files are added to the extension. "background.js", if present, is
+	// used as the background script.
+	Files map[string][]byte
+}
+
+// unsignedExtensionPrefs let a profile load webExtensions from its extensions
+// directory without asking.
+var unsignedExtensionPrefs = map[string]interface{}{
+	"xpinstall.signatures.required": false,
+	"extensions.autoDisableScopes":  0,
+	"extensions.enabledScopes":      1,
+}
+
+// xpi returns the packaged extension.
+func (e *webExtension) xpi() ([]byte, error) {
+	manifest := map[string]interface{}{
+		"manifest_version": 2,
+		"name":             e.Name,
+		"version":          "1.0",
+		"browser_specific_settings": map[string]interface{}{
+			"gecko": map[string]interface{}{"id": e.ID},
+		},
+	}
+	if len(e.Permissions) > 0 {
+		manifest["permissions"] = e.Permissions
+	}
+	if _, ok := e.Files["background.js"]; ok {
+		manifest["background"] = map[string]interface{}{"scripts": []string{"background.js"}}
+	}
+	for key, value := range e.Manifest {
+		manifest[key] = value
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{"manifest.json": manifestJSON}
+	for name, content := range e.Files {
+		files[name] = content
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// installExtension writes e into the profile's extensions directory and sets
+// the preferences needed to load it.
+func (p *Profile) installExtension(e *webExtension) error {
+	xpi, err := e.xpi()
+	if err != nil {
+		return err
+	}
+	if err := p.mkdir("extensions"); err != nil {
+		return err
+	}
+	if err := p.writeFile(filepath.Join("extensions", e.ID+".xpi"), xpi, 0o644); err != nil {
+		return err
+	}
+	return p.SetPrefs(unsignedExtensionPrefs)
+}
+
+// checkUnsignedExtensions returns an error wrapping ErrUnsignedExtensions if
+// the Firefox at executable is a release or beta build from Mozilla, which
+// cannot be made to load unsigned extensions, or an error if it cannot tell.
+func checkUnsignedExtensions(executable string) error {
+	dir, err := InstallDir(executable)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, "defaults", "pref", "channel-prefs.js")
+	pf, err := loadPrefFile(path)
+	if err != nil {
+		return err
+	}
+	channel, ok := pf.get("app.update.channel")
+	if !ok {
+		return fmt.Errorf("%s does not set the update channel", path)
+	}
+	if channel != "release" && channel != "beta" {
+		return nil
+	}
+	// Forks like LibreWolf are built from the release channel, but without
+	// requiring signatures.
+	ini, err := readINI(filepath.Join(dir, "application.ini"))
+	if err != nil {
+		return err
+	}
+	for _, section := range ini {
+		if section.name == "App" {
+			if name, _ := section.get("Name"); name != "Firefox" {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s channel: %w", channel, ErrUnsignedExtensions)
+}